@@ -9,11 +9,11 @@ import (
 	"syscall"
 	"time"
 
+	"alicloud-exporter/internal/client"
 	"alicloud-exporter/internal/config"
 	"alicloud-exporter/internal/exporter"
 	"alicloud-exporter/internal/logger"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 )
 
@@ -28,6 +28,9 @@ var (
 	logLevel    string
 	logFormat   string
 	showVersion bool
+	namespace   string
+	recordDir   string
+	replayDir   string
 )
 
 func main() {
@@ -48,6 +51,7 @@ The exporter provides standardized Prometheus metrics with proper labeling and e
 	rootCmd.Flags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
 	rootCmd.Flags().StringVar(&logFormat, "log-format", "json", "Log format (json, text)")
 	rootCmd.Flags().BoolVarP(&showVersion, "version", "v", false, "Show version information")
+	addTrafficFlags(rootCmd)
 
 	// Add version command
 	versionCmd := &cobra.Command{
@@ -74,9 +78,12 @@ The exporter provides standardized Prometheus metrics with proper labeling and e
 	// Add metrics list command
 	metricsCmd := &cobra.Command{
 		Use:   "metrics",
-		Short: "List available metrics for each service",
-		Run:   listMetrics,
+		Short: "List available metrics of each configured service from Alicloud CMS",
+		RunE:  listMetrics,
 	}
+	metricsCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to configuration file")
+	metricsCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "List metrics of this CMS namespace only")
+	addTrafficFlags(metricsCmd)
 	rootCmd.AddCommand(metricsCmd)
 
 	if err := rootCmd.Execute(); err != nil {
@@ -91,18 +98,23 @@ func runExporter(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Load configuration
-	cfg, err := config.Load(configFile)
-	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+	// Load configuration, also used to reload it
+	loadConfig := func() (*config.Config, error) {
+		return config.Load(configFile, func(cfg *config.Config) {
+			// Override log settings from command line if provided
+			if cmd.Flags().Changed("log-level") {
+				cfg.Server.LogLevel = logLevel
+			}
+			if cmd.Flags().Changed("log-format") {
+				cfg.Server.LogFormat = logFormat
+			}
+			applyTrafficFlags(cmd, cfg)
+		})
 	}
 
-	// Override log settings from command line if provided
-	if cmd.Flags().Changed("log-level") {
-		cfg.Server.LogLevel = logLevel
-	}
-	if cmd.Flags().Changed("log-format") {
-		cfg.Server.LogFormat = logFormat
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
 	// Initialize logger
@@ -120,11 +132,9 @@ func runExporter(cmd *cobra.Command, args []string) error {
 	}
 	defer exp.Close()
 
-	// Register exporter with Prometheus
+	// Optionally register Go and process metrics, the metrics handler adds the exporter's
+	// metrics to them per scrape
 	registry := prometheus.NewRegistry()
-	registry.MustRegister(exp)
-
-	// Optionally register Go and process metrics
 	if cfg.Prometheus.IncludeGoMetrics {
 		registry.MustRegister(prometheus.NewGoCollector())
 	}
@@ -134,10 +144,16 @@ func runExporter(cmd *cobra.Command, args []string) error {
 
 	// Setup HTTP server
 	mux := http.NewServeMux()
-	mux.Handle(cfg.Server.MetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{
-		ErrorLog:      log.Logger,
-		ErrorHandling: promhttp.ContinueOnError,
-	}))
+	mux.Handle(cfg.Server.MetricsPath, exp.MetricsHandler(registry))
+
+	// Add multi-target probe endpoint
+	mux.Handle("/probe", exp.ProbeHandler())
+
+	// Add Prometheus HTTP service discovery endpoint
+	mux.Handle("/sd/targets", exp.SDHandler())
+
+	// Add configuration reload endpoint
+	mux.Handle("/-/reload", exp.ReloadHandler(loadConfig))
 
 	// Add health check endpoint
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -153,7 +169,9 @@ func runExporter(cmd *cobra.Command, args []string) error {
 <head><title>Alicloud Exporter</title></head>
 <body>
 <h1>Alicloud Exporter</h1>
-<p><a href="%s">Metrics</a></p>
+<p><a href="%s">Metrics</a> (collect[] parameters select collectors)</p>
+<p><a href="/probe">Probe</a> (account, region, service and instance parameters)</p>
+<p><a href="/sd/targets">Service discovery</a> (account, region and service parameters)</p>
 <p><a href="/health">Health</a></p>
 <p>Version: %s</p>
 </body>
@@ -164,7 +182,7 @@ func runExporter(cmd *cobra.Command, args []string) error {
 		Addr:         cfg.Server.ListenAddress,
 		Handler:      mux,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: exporter.MaxScrapeTimeout + 10*time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
@@ -176,10 +194,22 @@ func runExporter(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
+	// Reload configuration on SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			log.Info("Received SIGHUP, reloading configuration")
+			// Errors are logged and exported by the exporter
+			_ = exp.Reload(loadConfig)
+		}
+	}()
+
 	// Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-sigChan
+	signal.Stop(reloadChan)
 
 	log.WithField("signal", sig.String()).Info("Received shutdown signal")
 
@@ -208,64 +238,88 @@ func validateConfig(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Configuration file '%s' is valid\n", configFile)
 	fmt.Printf("Services enabled:\n")
-	if cfg.Services.SLB.Enabled {
-		fmt.Printf("  - SLB: %d metrics\n", len(cfg.Services.SLB.Metrics))
-	}
-	if cfg.Services.Redis.Enabled {
-		fmt.Printf("  - Redis: %d metrics\n", len(cfg.Services.Redis.Metrics))
-	}
-	if cfg.Services.RDS.Enabled {
-		fmt.Printf("  - RDS: %d metrics\n", len(cfg.Services.RDS.Metrics))
+	for _, name := range cfg.Services.Names() {
+		service, _ := cfg.Services.Get(name)
+		if service.Enabled {
+			fmt.Printf("  - %s (%s): %d metrics\n", name, service.Namespace, len(service.Metrics))
+		}
 	}
 
 	return nil
 }
 
-func listMetrics(cmd *cobra.Command, args []string) {
-	fmt.Println("Available metrics by service:")
-	fmt.Println()
-
-	fmt.Println("SLB (Server Load Balancer):")
-	for _, metric := range getSLBMetrics() {
-		fmt.Printf("  - %s\n", metric)
+func listMetrics(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(configFile, func(cfg *config.Config) {
+		applyTrafficFlags(cmd, cfg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
 	}
-	fmt.Println()
 
-	fmt.Println("Redis (KVStore):")
-	for _, metric := range getRedisMetrics() {
-		fmt.Printf("  - %s\n", metric)
+	alicloudClient, err := client.NewClient(&cfg.Alicloud)
+	if err != nil {
+		return fmt.Errorf("failed to create Alicloud client: %w", err)
 	}
-	fmt.Println()
+	defer alicloudClient.Close()
 
-	fmt.Println("RDS (Relational Database Service):")
-	for _, metric := range getRDSMetrics() {
-		fmt.Printf("  - %s\n", metric)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// List the requested namespace, or the namespaces of the enabled services
+	titles := make(map[string]string)
+	var namespaces []string
+	if namespace != "" {
+		namespaces = []string{namespace}
+	} else {
+		for _, name := range cfg.Services.Names() {
+			service, _ := cfg.Services.Get(name)
+			if service.Enabled && service.Namespace != "" {
+				namespaces = append(namespaces, service.Namespace)
+				titles[service.Namespace] = name
+			}
+		}
 	}
-}
 
-// Metric lists (simplified versions for CLI)
-func getSLBMetrics() []string {
-	return []string{
-		"ActiveConnection", "NewConnection", "DropConnection", "InactiveConnection",
-		"MaxConnection", "Qps", "Rt", "StatusCode2xx", "StatusCode3xx",
-		"StatusCode4xx", "StatusCode5xx", "TrafficRXNew", "TrafficTXNew",
-		"HeathyServerCount", "UnhealthyServerCount",
+	for _, ns := range namespaces {
+		metas, err := alicloudClient.DescribeMetricMeta(ctx, ns)
+		if err != nil {
+			return err
+		}
+
+		if title, found := titles[ns]; found {
+			fmt.Printf("%s (%s):\n", title, ns)
+		} else {
+			fmt.Printf("%s:\n", ns)
+		}
+		for _, meta := range metas {
+			fmt.Printf("  - %s [%s] %s\n", meta.MetricName, meta.Unit, meta.Description)
+		}
+		fmt.Println()
 	}
+
+	return nil
 }
 
-func getRedisMetrics() []string {
-	return []string{
-		"ConnectionUsage", "CpuUsage", "MemoryUsage", "UsedMemory",
-		"UsedConnection", "UsedQPS", "IntranetIn", "IntranetOut",
-		"IntranetInRatio", "IntranetOutRatio", "FailedCount",
-	}
+// addTrafficFlags adds the flags recording or replaying Alicloud API traffic to a command
+func addTrafficFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&recordDir, "record-dir", "", "Record Alicloud API requests and responses as sanitized JSON fixtures in this directory")
+	cmd.Flags().StringVar(&replayDir, "replay-dir", "", "Answer Alicloud API requests from the JSON fixtures in this directory, without network access")
 }
 
-func getRDSMetrics() []string {
-	return []string{
-		"ConnectionUsage", "CpuUsage", "DiskUsage", "IOPSUsage",
-		"MemoryUsage", "MySQL_ActiveSessions", "MySQL_QPS", "MySQL_TPS",
-		"MySQL_NetworkInNew", "MySQL_NetworkOutNew", "MySQL_IbufDirtyRatio",
-		"MySQL_IbufUseRatio", "MySQL_InnoDBDataRead", "MySQL_InnoDBDataWritten",
+// applyTrafficFlags overrides the record and replay directories of the configuration with the
+// flags, a flag selecting one mode disables the other one set in the configuration file
+func applyTrafficFlags(cmd *cobra.Command, cfg *config.Config) {
+	record, replay := cmd.Flags().Changed("record-dir"), cmd.Flags().Changed("replay-dir")
+	if record {
+		cfg.Alicloud.RecordDir = recordDir
+		if !replay {
+			cfg.Alicloud.ReplayDir = ""
+		}
+	}
+	if replay {
+		cfg.Alicloud.ReplayDir = replayDir
+		if !record {
+			cfg.Alicloud.RecordDir = ""
+		}
 	}
 }
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/cms"
+)
+
+// MetricAPI reads CMS metric data and metadata
+type MetricAPI interface {
+	// GetMetricDataInRegionWithDimensions fetches the latest datapoints of a metric in a region,
+	// restricted to the series matching any of the dimension filters
+	GetMetricDataInRegionWithDimensions(ctx context.Context, region, namespace, metricName string, dimensions []Dimension) (*cms.DescribeMetricLastResponse, error)
+
+	// DescribeMetricMeta lists the metrics of a CMS namespace
+	DescribeMetricMeta(ctx context.Context, namespace string) ([]MetricMeta, error)
+
+	// GetRegions returns the regions metrics are collected from
+	GetRegions() []string
+}
+
+// DescribeAPI lists the instances of the services with an inventory and their tags
+type DescribeAPI interface {
+	DescribeSLBInstances(ctx context.Context) ([]Instance, error)
+	DescribeRDSInstances(ctx context.Context) ([]Instance, error)
+	DescribeRedisInstances(ctx context.Context) ([]Instance, error)
+
+	GetSLBInstanceTags(ctx context.Context, instanceIDs []string) (map[string]map[string]string, error)
+
+	// GetRDSInstanceTags and GetRedisInstanceTags look up the tags of instances in the region
+	// they are in, which is the region their metrics were collected from
+	GetRDSInstanceTags(ctx context.Context, region string, instanceIDs []string) (map[string]map[string]string, error)
+	GetRedisInstanceTags(ctx context.Context, region string, instanceIDs []string) (map[string]map[string]string, error)
+}
+
+// HealthAPI checks connectivity to Alicloud
+type HealthAPI interface {
+	Health(ctx context.Context) error
+}
+
+// API is the Alicloud API used by collectors, implemented by Client and by the fake in clienttest
+type API interface {
+	MetricAPI
+	DescribeAPI
+	HealthAPI
+}
+
+var _ API = (*Client)(nil)
@@ -2,12 +2,21 @@ package client
 
 import (
 	"alicloud-exporter/internal/config"
+	"alicloud-exporter/internal/logger"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/cms"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/r_kvstore"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/rds"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
 )
 
@@ -23,56 +32,6 @@ func (ce *CacheEntry) IsExpired() bool {
 	return time.Since(ce.Timestamp) > ce.TTL
 }
 
-// TagCache implements an in-memory cache for SLB tags and regions
-type TagCache struct {
-	cache       map[string]map[string]string
-	regionCache map[string]string
-	mu          sync.RWMutex
-	ttl         time.Duration
-}
-
-// NewTagCache creates a new tag cache
-func NewTagCache(ttl time.Duration) *TagCache {
-	return &TagCache{
-		cache:       make(map[string]map[string]string),
-		regionCache: make(map[string]string),
-		ttl:         ttl,
-	}
-}
-
-// Get retrieves tags from cache
-func (tc *TagCache) Get(key string) (map[string]string, bool) {
-	tc.mu.RLock()
-	defer tc.mu.RUnlock()
-	tags, found := tc.cache[key]
-	return tags, found
-}
-
-// GetWithRegion retrieves tags and region from cache
-func (tc *TagCache) GetWithRegion(key string) (map[string]string, string, bool) {
-	tc.mu.RLock()
-	defer tc.mu.RUnlock()
-	tags, found := tc.cache[key]
-	region := tc.regionCache[key]
-	return tags, region, found
-}
-
-// Set stores tags in cache
-func (tc *TagCache) Set(key string, tags map[string]string) {
-	tc.mu.Lock()
-	defer tc.mu.Unlock()
-	tc.cache[key] = tags
-}
-
-// SetWithRegion stores tags and region in cache
-func (tc *TagCache) SetWithRegion(key string, tags map[string]string, region string) {
-	tc.mu.Lock()
-	defer tc.mu.Unlock()
-	tc.cache[key] = tags
-	tc.regionCache[key] = region
-}
-
-
 // MetricCache implements an in-memory cache for metric data
 type MetricCache struct {
 	cache map[string]*CacheEntry
@@ -127,12 +86,28 @@ func (mc *MetricCache) Clear() {
 // Client wraps the Alicloud CMS client with additional functionality
 type Client struct {
 	cmsClient   *cms.Client
+	cmsClients  map[string]*cms.Client // Multi-region CMS clients
 	slbClient   *slb.Client
-	slbClients  map[string]*slb.Client // Multi-region SLB clients
+	slbClients  map[string]*slb.Client       // Multi-region SLB clients
+	rdsClients  map[string]*rds.Client       // Multi-region RDS clients
+	kvClients   map[string]*r_kvstore.Client // Multi-region Redis (KVStore) clients
 	config      *config.AlicloudConfig
+	credentials credentials.CredentialsProvider
 	rateLimiter *RateLimiter
+	retryPolicy RetryPolicy
 	cache       *MetricCache
-	tagCache    *TagCache // Add tag cache
+	flights     *flightGroup[*cms.DescribeMetricLastResponse] // In-flight DescribeMetricLast requests, keyed like the cache
+	listings    *flightGroup[[]Instance]                      // In-flight inventory listings, keyed like the inventory
+	tagCache    *TagCache                                     // Add tag cache
+	background  context.Context                               // Canceled on Close to stop background refreshes
+	stop        context.CancelFunc
+	inventory   *InventoryCache
+	metaCache   *MetaCache
+	endpoints   map[string]endpoint
+	transport   http.RoundTripper // Records or replays API traffic, nil to send requests normally
+	metrics     *Metrics
+	metricsMu   sync.RWMutex // Guards metrics, which is read by requests made under c.mu
+	logger      *logger.Logger
 	mu          sync.RWMutex
 }
 
@@ -148,62 +123,134 @@ type RateLimiter struct {
 
 // NewClient creates a new Alicloud client
 func NewClient(cfg *config.AlicloudConfig) (*Client, error) {
-	cmsClient, err := cms.NewClientWithAccessKey(
-		cfg.Region,
-		cfg.AccessKeyID,
-		cfg.AccessKeySecret,
-	)
+	credentialsProvider, err := NewCredentialsProvider(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credentials provider: %w", err)
+	}
+
+	endpoints, err := parseEndpoints(cfg.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	// API traffic is recorded to or replayed from fixtures when configured
+	transport, err := newTrafficTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cmsClient, err := cms.NewClientWithOptions(cfg.Region, newSDKConfig(transport), credentialsProvider)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CMS client: %w", err)
 	}
+	applyEndpoint(&cmsClient.Client, endpoints, "cms")
 
-	slbClient, err := slb.NewClientWithAccessKey(
-		cfg.Region,
-		cfg.AccessKeyID,
-		cfg.AccessKeySecret,
-	)
+	slbClient, err := slb.NewClientWithOptions(cfg.Region, newSDKConfig(transport), credentialsProvider)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SLB client: %w", err)
 	}
+	applyEndpoint(&slbClient.Client, endpoints, "slb")
 
-	// Create SLB clients for multiple regions
+	// Create CMS and SLB clients for multiple regions
 	regions := cfg.Regions
 	if len(regions) == 0 {
 		// Fallback to primary region if no regions specified
 		regions = []string{cfg.Region}
 	}
+	cmsClients := make(map[string]*cms.Client)
+	for _, region := range regions {
+		if region == cfg.Region {
+			cmsClients[region] = cmsClient
+			continue
+		}
+		regionClient, err := cms.NewClientWithOptions(region, newSDKConfig(transport), credentialsProvider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CMS client for region %s: %w", region, err)
+		}
+		applyEndpoint(&regionClient.Client, endpoints, "cms")
+		cmsClients[region] = regionClient
+	}
+
 	slbClients := make(map[string]*slb.Client)
 	for _, region := range regions {
-		regionClient, err := slb.NewClientWithAccessKey(
-			region,
-			cfg.AccessKeyID,
-			cfg.AccessKeySecret,
-		)
+		regionClient, err := slb.NewClientWithOptions(region, newSDKConfig(transport), credentialsProvider)
 		if err != nil {
-			// Log warning but continue with other regions
-			continue
+			return nil, fmt.Errorf("failed to create SLB client for region %s: %w", region, err)
 		}
+		applyEndpoint(&regionClient.Client, endpoints, "slb")
 		slbClients[region] = regionClient
 	}
 
+	// Create RDS and Redis clients used to look up instance tags
+	rdsClients := make(map[string]*rds.Client)
+	kvClients := make(map[string]*r_kvstore.Client)
+	for _, region := range regions {
+		rdsClient, err := rds.NewClientWithOptions(region, newSDKConfig(transport), credentialsProvider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create RDS client for region %s: %w", region, err)
+		}
+		applyEndpoint(&rdsClient.Client, endpoints, "rds")
+		rdsClients[region] = rdsClient
+
+		kvClient, err := r_kvstore.NewClientWithOptions(region, newSDKConfig(transport), credentialsProvider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Redis client for region %s: %w", region, err)
+		}
+		applyEndpoint(&kvClient.Client, endpoints, "redis")
+		kvClients[region] = kvClient
+	}
+
 	// Create rate limiter
 	rateLimiter := NewRateLimiter(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
 
 	// Create cache with 30 second TTL
 	cache := NewMetricCache(30 * time.Second)
 
-	// Create tag cache with 5 minute TTL
-	tagCache := NewTagCache(5 * time.Minute)
+	// Create tag cache, expiring and bounded as configured
+	tagCache := NewTagCache(cfg.TagCache)
+
+	// Create inventory cache with 5 minute TTL, kept for two refresh intervals when refreshed in the
+	// background so that a failed refresh doesn't empty it
+	inventoryTTL := 5 * time.Minute
+	if cfg.InventoryRefreshInterval > 0 {
+		inventoryTTL = 2 * cfg.InventoryRefreshInterval
+	}
+	inventory := NewInventoryCache(inventoryTTL)
+
+	// Create metric metadata cache with 1 hour TTL
+	metaCache := NewMetaCache(time.Hour)
+
+	background, stop := context.WithCancel(context.Background())
 
-	return &Client{
+	c := &Client{
 		cmsClient:   cmsClient,
+		cmsClients:  cmsClients,
 		slbClient:   slbClient,
 		slbClients:  slbClients,
+		rdsClients:  rdsClients,
+		kvClients:   kvClients,
 		cache:       cache,
+		flights:     newFlightGroup[*cms.DescribeMetricLastResponse](),
+		listings:    newFlightGroup[[]Instance](),
 		tagCache:    tagCache, // Add tag cache
+		background:  background,
+		stop:        stop,
+		inventory:   inventory,
+		metaCache:   metaCache,
+		endpoints:   endpoints,
+		transport:   transport,
 		config:      cfg,
+		credentials: credentialsProvider,
 		rateLimiter: rateLimiter,
-	}, nil
+		retryPolicy: NewRetryPolicy(cfg.Retry),
+		logger:      logger.Discard(),
+	}
+
+	if cfg.InventoryRefreshInterval > 0 {
+		go c.refreshInventory(cfg.InventoryRefreshInterval)
+	}
+
+	return c, nil
 }
 
 // NewRateLimiter creates a new rate limiter
@@ -266,33 +313,63 @@ func (rl *RateLimiter) Close() {
 	rl.ticker.Stop()
 }
 
-// GetMetricData retrieves metric data from Alicloud CMS with caching
+// GetMetricData retrieves metric data from Alicloud CMS in the primary region with caching
 func (c *Client) GetMetricData(ctx context.Context, namespace, metricName string) (*cms.DescribeMetricLastResponse, error) {
+	return c.GetMetricDataInRegion(ctx, c.config.Region, namespace, metricName)
+}
+
+// GetMetricDataInRegion retrieves metric data from Alicloud CMS in the given region with caching
+func (c *Client) GetMetricDataInRegion(ctx context.Context, region, namespace, metricName string) (*cms.DescribeMetricLastResponse, error) {
+	return c.GetMetricDataInRegionWithDimensions(ctx, region, namespace, metricName, nil)
+}
+
+// GetMetricDataInRegionWithDimensions retrieves metric data from Alicloud CMS in the given region
+// with caching, restricted to the series matching any of the dimensions
+func (c *Client) GetMetricDataInRegionWithDimensions(ctx context.Context, region, namespace, metricName string, dimensions []Dimension) (*cms.DescribeMetricLastResponse, error) {
+	chunks, err := encodeDimensions(dimensions, maxDimensionsPerRequest)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create cache key
-	cacheKey := fmt.Sprintf("%s:%s", namespace, metricName)
+	cacheKey := fmt.Sprintf("%s:%s:%s", region, namespace, metricName)
+	if len(dimensions) > 0 {
+		cacheKey += ":" + strings.Join(chunks, "")
+	}
 
 	// Check cache first
 	if cachedData, found := c.cache.Get(cacheKey); found {
 		return cachedData, nil
 	}
 
-	// Wait for rate limiter
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		return nil, fmt.Errorf("rate limiter wait failed: %w", err)
+	// Concurrent scrapes of the same metric share a single request
+	response, shared, err := c.flights.do(ctx, cacheKey, func() (*cms.DescribeMetricLastResponse, error) {
+		// The cache may have been filled by a request that completed since it was checked
+		if cachedData, found := c.cache.Get(cacheKey); found {
+			return cachedData, nil
+		}
+		return c.fetchMetricData(ctx, region, namespace, metricName, chunks, cacheKey)
+	})
+	if shared {
+		c.getMetrics().recordDeduplicated(namespace, metricName)
+	}
+	return response, err
+}
+
+// fetchMetricData fetches metric data from Alicloud CMS and caches the response
+func (c *Client) fetchMetricData(ctx context.Context, region, namespace, metricName string, chunks []string, cacheKey string) (*cms.DescribeMetricLastResponse, error) {
+	cmsClient, err := c.cmsClientForRegion(region)
+	if err != nil {
+		return nil, err
 	}
 
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	request := cms.CreateDescribeMetricLastRequest()
-	request.Scheme = "https"
-	request.MetricName = metricName
-	request.Namespace = namespace
-	request.AcceptFormat = "json"
-
-	response, err := c.cmsClient.DescribeMetricLast(request)
+	response, err := c.describeMetricLast(ctx, cmsClient, namespace, metricName, chunks)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get metric data for %s/%s: %w", namespace, metricName, err)
+		c.getMetrics().recordRegionError(region, namespace)
+		return nil, err
 	}
 
 	// Cache the response
@@ -301,43 +378,152 @@ func (c *Client) GetMetricData(ctx context.Context, namespace, metricName string
 	return response, nil
 }
 
-// GetMetricDataWithDimensions retrieves metric data with specific dimensions
-func (c *Client) GetMetricDataWithDimensions(ctx context.Context, namespace, metricName string, dimensions map[string]string) (*cms.DescribeMetricLastResponse, error) {
-	// Wait for rate limiter
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		return nil, fmt.Errorf("rate limiter wait failed: %w", err)
+// cmsClientForRegion returns the CMS client of a region, creating it on first use for
+// regions that are only configured on individual services
+func (c *Client) cmsClientForRegion(region string) (*cms.Client, error) {
+	c.mu.RLock()
+	cmsClient, exists := c.cmsClients[region]
+	c.mu.RUnlock()
+	if exists {
+		return cmsClient, nil
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	request := cms.CreateDescribeMetricLastRequest()
-	request.Scheme = "https"
-	request.MetricName = metricName
-	request.Namespace = namespace
-	request.AcceptFormat = "json"
+	if cmsClient, exists := c.cmsClients[region]; exists {
+		return cmsClient, nil
+	}
 
-	// Add dimensions if provided
-	if len(dimensions) > 0 {
-		dimensionsJSON := "["
-		first := true
-		for _, value := range dimensions {
-			if !first {
-				dimensionsJSON += ","
-			}
-			dimensionsJSON += fmt.Sprintf(`{"instanceId":"%s"}`, value)
-			first = false
+	cmsClient, err := cms.NewClientWithOptions(region, newSDKConfig(c.transport), c.credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CMS client for region %s: %w", region, err)
+	}
+	applyEndpoint(&cmsClient.Client, c.endpoints, "cms")
+	c.cmsClients[region] = cmsClient
+
+	return cmsClient, nil
+}
+
+// GetMetricDataWithDimensions retrieves metric data in the primary region, restricted to the
+// series matching any of the dimensions
+func (c *Client) GetMetricDataWithDimensions(ctx context.Context, namespace, metricName string, dimensions []Dimension) (*cms.DescribeMetricLastResponse, error) {
+	return c.GetMetricDataInRegionWithDimensions(ctx, c.config.Region, namespace, metricName, dimensions)
+}
+
+// describeMetricLast fetches a metric once per chunk of encoded dimensions and merges the
+// Datapoints of every chunk and page into the returned response
+func (c *Client) describeMetricLast(ctx context.Context, cmsClient *cms.Client, namespace, metricName string, dimensionChunks []string) (*cms.DescribeMetricLastResponse, error) {
+	var merged *cms.DescribeMetricLastResponse
+	datapoints := make([]json.RawMessage, 0)
+
+	for _, chunk := range dimensionChunks {
+		request := cms.CreateDescribeMetricLastRequest()
+		request.Scheme = c.scheme("cms")
+		request.MetricName = metricName
+		request.Namespace = namespace
+		request.AcceptFormat = "json"
+		request.Dimensions = chunk
+
+		response, page, err := c.describeMetricLastAllPages(ctx, cmsClient, request)
+		if err != nil {
+			return nil, err
 		}
-		dimensionsJSON += "]"
-		request.Dimensions = dimensionsJSON
+		if merged == nil {
+			merged = response
+		}
+		datapoints = append(datapoints, page...)
+	}
+
+	merged.NextToken = ""
+	if len(datapoints) == 0 {
+		merged.Datapoints = ""
+		return merged, nil
 	}
 
-	response, err := c.cmsClient.DescribeMetricLast(request)
+	data, err := json.Marshal(datapoints)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get metric data for %s/%s: %w", namespace, metricName, err)
+		return nil, fmt.Errorf("failed to merge datapoints for %s/%s: %w", namespace, metricName, err)
 	}
+	merged.Datapoints = string(data)
 
-	return response, nil
+	return merged, nil
+}
+
+// describeMetricLastAllPages executes a DescribeMetricLast request and follows NextToken until
+// all pages are fetched, returning the first response and the Datapoints of every page
+func (c *Client) describeMetricLastAllPages(ctx context.Context, cmsClient *cms.Client, request *cms.DescribeMetricLastRequest) (*cms.DescribeMetricLastResponse, []json.RawMessage, error) {
+	if c.config.MetricPageSize > 0 {
+		request.Length = strconv.Itoa(c.config.MetricPageSize)
+	}
+
+	var first *cms.DescribeMetricLastResponse
+	datapoints := make([]json.RawMessage, 0)
+
+	for {
+		// Every page is rate limited and retried on its own
+		var response *cms.DescribeMetricLastResponse
+		err := c.withRetry(ctx, "DescribeMetricLast", func() error {
+			var callErr error
+			response, callErr = cmsClient.DescribeMetricLast(request)
+			return callErr
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get metric data for %s/%s: %w", request.Namespace, request.MetricName, err)
+		}
+		c.getMetrics().recordPage(request.Namespace, request.MetricName)
+
+		if response.Datapoints != "" {
+			var page []json.RawMessage
+			if err := json.Unmarshal([]byte(response.Datapoints), &page); err != nil {
+				return nil, nil, fmt.Errorf("failed to unmarshal datapoints for %s/%s: %w", request.Namespace, request.MetricName, err)
+			}
+			datapoints = append(datapoints, page...)
+		}
+
+		if first == nil {
+			first = response
+		}
+
+		// Stop when there are no more pages
+		if response.NextToken == "" || response.NextToken == request.NextToken {
+			break
+		}
+		request.NextToken = response.NextToken
+	}
+
+	return first, datapoints, nil
+}
+
+// SetMetrics attaches Prometheus metrics describing API usage to the client
+func (c *Client) SetMetrics(metrics *Metrics) {
+	c.metricsMu.Lock()
+	defer c.metricsMu.Unlock()
+	c.metrics = metrics
+	c.tagCache.setMetrics(metrics)
+}
+
+// getMetrics returns the metrics set with SetMetrics. Requests hold c.mu while recording them,
+// so the metrics are read under their own lock rather than c.mu.
+func (c *Client) getMetrics() *Metrics {
+	c.metricsMu.RLock()
+	defer c.metricsMu.RUnlock()
+	return c.metrics
+}
+
+// SetLogger sets the logger of errors the client can't return, such as failed background refreshes
+func (c *Client) SetLogger(log *logger.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = log
+}
+
+// getLogger returns the logger set with SetLogger. Background refreshes started by NewClient run
+// concurrently with SetLogger, so the logger is read under c.mu; the caller must not hold it.
+func (c *Client) getLogger() *logger.Logger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.logger
 }
 
 // Close closes the client and releases resources
@@ -345,6 +531,7 @@ func (c *Client) Close() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.stop()
 	if c.rateLimiter != nil {
 		c.rateLimiter.Close()
 	}
@@ -355,15 +542,32 @@ func (c *Client) GetRegion() string {
 	return c.config.Region
 }
 
+// GetRegions returns the configured regions, sorted by name
+func (c *Client) GetRegions() []string {
+	regions := c.config.Regions
+	if len(regions) == 0 {
+		// Fallback to primary region if no regions specified
+		return []string{c.config.Region}
+	}
+
+	sorted := make([]string, len(regions))
+	copy(sorted, regions)
+	sort.Strings(sorted)
+	return sorted
+}
+
 // Health checks the health of the client
 func (c *Client) Health(ctx context.Context) error {
 	// Try to make a simple request to test connectivity
 	request := cms.CreateDescribeMetricMetaListRequest()
-	request.Scheme = "https"
+	request.Scheme = c.scheme("cms")
 	request.Namespace = "acs_ecs_dashboard"
 	request.PageSize = "1"
 
-	_, err := c.cmsClient.DescribeMetricMetaList(request)
+	err := c.withRetry(ctx, "DescribeMetricMetaList", func() error {
+		_, callErr := c.cmsClient.DescribeMetricMetaList(request)
+		return callErr
+	})
 	if err != nil {
 		return fmt.Errorf("health check failed: %w", err)
 	}
@@ -378,7 +582,8 @@ func (c *Client) GetSLBInstanceTags(ctx context.Context, instanceIDs []string) (
 	return tags, err
 }
 
-// GetSLBInstanceTagsWithRegion retrieves tags and region info for SLB instances
+// GetSLBInstanceTagsWithRegion retrieves tags and region info for SLB instances. Stale tags are
+// returned as is and refreshed in the background.
 func (c *Client) GetSLBInstanceTagsWithRegion(ctx context.Context, instanceIDs []string) (map[string]map[string]string, map[string]string, error) {
 	if len(instanceIDs) == 0 {
 		return make(map[string]map[string]string), make(map[string]string), nil
@@ -386,87 +591,73 @@ func (c *Client) GetSLBInstanceTagsWithRegion(ctx context.Context, instanceIDs [
 
 	tagsMap := make(map[string]map[string]string)
 	regionMap := make(map[string]string)
-	uncachedIDs := make([]string, 0)
+	var uncachedIDs, staleIDs []string
 
 	// Check cache first to avoid unnecessary API calls
 	for _, id := range instanceIDs {
-		if tags, region, found := c.tagCache.GetWithRegion(id); found {
-			tagsMap[id] = tags
-			if region != "" {
-				regionMap[id] = region
-			}
-		} else {
+		tags, region, found, refresh := c.tagCache.Lookup(id)
+		if !found {
 			uncachedIDs = append(uncachedIDs, id)
+			continue
+		}
+		tagsMap[id] = tags
+		if region != "" {
+			regionMap[id] = region
+		}
+		if refresh {
+			staleIDs = append(staleIDs, id)
 		}
 	}
 
+	c.refreshTags(staleIDs, func(ctx context.Context, ids []string) error {
+		_, _, err := c.fetchSLBTags(ctx, ids)
+		return err
+	})
+
 	// If all instances are cached, return immediately
 	if len(uncachedIDs) == 0 {
 		return tagsMap, regionMap, nil
 	}
 
-	// Create a set of uncached IDs for efficient lookup and removal
-	uncachedSet := make(map[string]bool)
-	for _, id := range uncachedIDs {
-		uncachedSet[id] = true
+	foundTags, foundRegions, err := c.fetchSLBTags(ctx, uncachedIDs)
+	for id, tags := range foundTags {
+		tagsMap[id] = tags
 	}
+	for id, region := range foundRegions {
+		regionMap[id] = region
+	}
+	return tagsMap, regionMap, err
+}
 
-	// Iterate through all configured regions to find instances
-	for region, slbClient := range c.slbClients {
-		// Early exit if all instances have been found
-		if len(uncachedSet) == 0 {
-			break
-		}
-
-		// Apply rate limiting before API call
-		if err := c.rateLimiter.Wait(ctx); err != nil {
-			return tagsMap, regionMap, fmt.Errorf("rate limiter error: %w", err)
-		}
+// fetchSLBTags looks up the tags and region of SLB instances in the inventory of every configured
+// region, served from the inventory cache and listing only the regions whose cache expired. Load
+// balancers created since the inventory was listed are found once it is listed again, when its
+// cache expires or by the refresh every inventory_refresh_interval.
+func (c *Client) fetchSLBTags(ctx context.Context, instanceIDs []string) (map[string]map[string]string, map[string]string, error) {
+	tagsMap := make(map[string]map[string]string)
+	regionMap := make(map[string]string)
 
-		// Create and configure the DescribeLoadBalancers request
-		// Note: DescribeLoadBalancers API doesn't support batch querying by LoadBalancerId
-		// We need to query all load balancers and filter by the ones we need
-		request := slb.CreateDescribeLoadBalancersRequest()
-		request.Scheme = "https"
-		// Don't set LoadBalancerId - query all load balancers in the region
+	instances, err := c.describeInstances(ctx, "slb", true)
+	found := make(map[string]Instance, len(instances))
+	for _, instance := range instances {
+		found[instance.ID] = instance
+	}
 
-		// Execute the API call
-		response, err := slbClient.DescribeLoadBalancers(request)
-		if err != nil {
-			// Log error with region context but continue to next region
-			fmt.Printf("Failed to describe load balancers in region %s: %v\n", region, err)
+	for _, id := range instanceIDs {
+		instance, exists := found[id]
+		if exists {
+			tagsMap[id] = instance.Tags
+			regionMap[id] = instance.Region
+			c.tagCache.SetWithRegion(id, instance.Tags, instance.Region)
 			continue
 		}
 
-		// Process each load balancer in the response
-		for _, lb := range response.LoadBalancers.LoadBalancer {
-			// Only process load balancers that we're looking for
-			if _, needed := uncachedSet[lb.LoadBalancerId]; !needed {
-				continue
-			}
-
-			// Extract tags from the load balancer
-			instanceTags := make(map[string]string)
-			for _, tag := range lb.Tags.Tag {
-				instanceTags[tag.TagKey] = tag.TagValue
-			}
-			
-			// Store in result map and cache
-			tagsMap[lb.LoadBalancerId] = instanceTags
-			regionMap[lb.LoadBalancerId] = region
-			c.tagCache.SetWithRegion(lb.LoadBalancerId, instanceTags, region)
-
-			// Remove found instance from uncached set
-			delete(uncachedSet, lb.LoadBalancerId)
+		// Instances of regions that failed may exist, only cache the absence of complete listings
+		tagsMap[id] = make(map[string]string)
+		if err == nil {
+			c.tagCache.SetMissing(id)
 		}
 	}
 
-	// Cache empty tags for instances that weren't found to avoid repeated API calls
-	for remainingID := range uncachedSet {
-		emptyTags := make(map[string]string)
-		tagsMap[remainingID] = emptyTags
-		c.tagCache.SetWithRegion(remainingID, emptyTags, "")
-	}
-
-	return tagsMap, regionMap, nil
+	return tagsMap, regionMap, err
 }
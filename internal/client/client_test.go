@@ -0,0 +1,753 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/client/clienttest"
+	"alicloud-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestClient creates a client pointed at a fake server serving the data of fake
+func newTestClient(t *testing.T, fake *clienttest.Fake, configure func(*config.AlicloudConfig)) *client.Client {
+	t.Helper()
+
+	server := clienttest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := &config.AlicloudConfig{
+		AccessKeyID:     "test-key",
+		AccessKeySecret: "test-secret",
+		Region:          "cn-hangzhou",
+		RateLimit:       config.RateLimitConfig{RequestsPerSecond: 1000, Burst: 1000},
+		Retry:           config.RetryConfig{MaxAttempts: 1},
+		Credentials:     config.CredentialsConfig{Type: "access_key"},
+		Endpoints:       clienttest.Endpoints(server),
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	c, err := client.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// datapoints returns count datapoints of instances i-0, i-1, ...
+func datapoints(count int) []clienttest.Datapoint {
+	points := make([]clienttest.Datapoint, 0, count)
+	for i := 0; i < count; i++ {
+		points = append(points, clienttest.Datapoint{
+			"instanceId": fmt.Sprintf("i-%d", i),
+			"timestamp":  1700000000000,
+			"Average":    float64(i),
+		})
+	}
+	return points
+}
+
+func TestGetMetricDataInRegionWithDimensions(t *testing.T) {
+	tests := []struct {
+		name           string
+		datapoints     int
+		pageSize       int
+		dimensions     []client.Dimension
+		wantDatapoints int
+		wantRequests   int
+	}{
+		{
+			name:           "single page",
+			datapoints:     3,
+			wantDatapoints: 3,
+			wantRequests:   1,
+		},
+		{
+			name:           "follows NextToken",
+			datapoints:     5,
+			pageSize:       2,
+			wantDatapoints: 5,
+			wantRequests:   3,
+		},
+		{
+			name:           "no datapoints",
+			wantDatapoints: 0,
+			wantRequests:   1,
+		},
+		{
+			name:       "dimension filter",
+			datapoints: 5,
+			dimensions: []client.Dimension{
+				{"instanceId": "i-1"},
+				{"instanceId": "i-3"},
+			},
+			wantDatapoints: 2,
+			wantRequests:   1,
+		},
+		{
+			name:           "dimension filters split into chunks",
+			datapoints:     80,
+			dimensions:     instanceFilters(60),
+			wantDatapoints: 60,
+			wantRequests:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clienttest.New()
+			fake.AddDatapoints("cn-hangzhou", "acs_test", "CpuUsage", datapoints(tt.datapoints)...)
+			c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+				cfg.MetricPageSize = tt.pageSize
+			})
+
+			response, err := c.GetMetricDataInRegionWithDimensions(context.Background(), "cn-hangzhou", "acs_test", "CpuUsage", tt.dimensions)
+			if err != nil {
+				t.Fatalf("GetMetricDataInRegionWithDimensions() error = %v", err)
+			}
+
+			var got []map[string]interface{}
+			if response.Datapoints != "" {
+				if err := json.Unmarshal([]byte(response.Datapoints), &got); err != nil {
+					t.Fatalf("invalid Datapoints %q: %v", response.Datapoints, err)
+				}
+			}
+			if len(got) != tt.wantDatapoints {
+				t.Errorf("got %d datapoints, want %d", len(got), tt.wantDatapoints)
+			}
+			if response.NextToken != "" {
+				t.Errorf("NextToken = %q, want it cleared after merging pages", response.NextToken)
+			}
+			if calls := fake.Calls("DescribeMetricLast"); calls != tt.wantRequests {
+				t.Errorf("got %d DescribeMetricLast requests, want %d", calls, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// instanceFilters returns dimension filters of instances i-0 to i-<count-1>
+func instanceFilters(count int) []client.Dimension {
+	filters := make([]client.Dimension, 0, count)
+	for i := 0; i < count; i++ {
+		filters = append(filters, client.Dimension{"instanceId": fmt.Sprintf("i-%d", i)})
+	}
+	return filters
+}
+
+func TestGetMetricDataCachesResponses(t *testing.T) {
+	fake := clienttest.New()
+	fake.AddDatapoints("cn-hangzhou", "acs_test", "CpuUsage", datapoints(1)...)
+	c := newTestClient(t, fake, nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetMetricDataInRegion(context.Background(), "cn-hangzhou", "acs_test", "CpuUsage"); err != nil {
+			t.Fatalf("GetMetricDataInRegion() error = %v", err)
+		}
+	}
+	if calls := fake.Calls("DescribeMetricLast"); calls != 1 {
+		t.Errorf("got %d DescribeMetricLast requests, want 1", calls)
+	}
+}
+
+func TestGetMetricDataError(t *testing.T) {
+	fake := clienttest.New()
+	fake.Errors["acs_test/CpuUsage"] = errors.New("boom")
+	c := newTestClient(t, fake, nil)
+
+	if _, err := c.GetMetricDataInRegion(context.Background(), "cn-hangzhou", "acs_test", "CpuUsage"); err == nil {
+		t.Fatal("GetMetricDataInRegion() error = nil, want server error")
+	}
+}
+
+func TestDescribeMetricMeta(t *testing.T) {
+	fake := clienttest.New()
+	metas := make([]client.MetricMeta, 0, 150)
+	for i := 0; i < 150; i++ {
+		metas = append(metas, client.MetricMeta{
+			MetricName: fmt.Sprintf("Metric%d", i),
+			Unit:       "%",
+			Statistics: []string{"Average", "Maximum"},
+		})
+	}
+	fake.Metas["acs_test"] = metas
+	c := newTestClient(t, fake, nil)
+
+	got, err := c.DescribeMetricMeta(context.Background(), "acs_test")
+	if err != nil {
+		t.Fatalf("DescribeMetricMeta() error = %v", err)
+	}
+	if len(got) != len(metas) {
+		t.Fatalf("got %d metrics, want %d", len(got), len(metas))
+	}
+	if got[0].Unit != "%" || len(got[0].Statistics) != 2 || got[0].Namespace != "acs_test" {
+		t.Errorf("got metadata %+v", got[0])
+	}
+	if calls := fake.Calls("DescribeMetricMetaList"); calls != 2 {
+		t.Errorf("got %d DescribeMetricMetaList requests, want 2", calls)
+	}
+}
+
+func TestDescribeSLBInstances(t *testing.T) {
+	tests := []struct {
+		name      string
+		regions   []string
+		instances map[string]int
+	}{
+		{
+			name:      "single page",
+			regions:   []string{"cn-hangzhou"},
+			instances: map[string]int{"cn-hangzhou": 3},
+		},
+		{
+			name:      "multiple pages",
+			regions:   []string{"cn-hangzhou"},
+			instances: map[string]int{"cn-hangzhou": 250},
+		},
+		{
+			name:      "multiple regions",
+			regions:   []string{"cn-hangzhou", "cn-shanghai"},
+			instances: map[string]int{"cn-hangzhou": 2, "cn-shanghai": 120},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clienttest.New(tt.regions...)
+			want := 0
+			for region, count := range tt.instances {
+				for i := 0; i < count; i++ {
+					fake.Instances["slb"] = append(fake.Instances["slb"], client.Instance{
+						ID:     fmt.Sprintf("lb-%s-%d", region, i),
+						Region: region,
+						Name:   fmt.Sprintf("lb %d", i),
+						Tags:   map[string]string{"Team": "infra"},
+					})
+				}
+				want += count
+			}
+			c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+				cfg.Regions = tt.regions
+			})
+
+			instances, err := c.DescribeSLBInstances(context.Background())
+			if err != nil {
+				t.Fatalf("DescribeSLBInstances() error = %v", err)
+			}
+			if len(instances) != want {
+				t.Fatalf("got %d instances, want %d", len(instances), want)
+			}
+			for _, instance := range instances {
+				if instance.Tags["Team"] != "infra" {
+					t.Errorf("instance %s has tags %v, want Team=infra", instance.ID, instance.Tags)
+				}
+			}
+
+			// Tags are cached by the inventory
+			tags, err := c.GetSLBInstanceTags(context.Background(), []string{instances[0].ID})
+			if err != nil {
+				t.Fatalf("GetSLBInstanceTags() error = %v", err)
+			}
+			if tags[instances[0].ID]["Team"] != "infra" {
+				t.Errorf("GetSLBInstanceTags() = %v, want Team=infra", tags)
+			}
+		})
+	}
+}
+
+func TestDescribeSLBInstancesError(t *testing.T) {
+	fake := clienttest.New()
+	fake.Errors["DescribeLoadBalancers"] = errors.New("boom")
+	c := newTestClient(t, fake, nil)
+
+	if _, err := c.DescribeSLBInstances(context.Background()); err == nil {
+		t.Fatal("DescribeSLBInstances() error = nil, want server error")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	fake := clienttest.New()
+	c := newTestClient(t, fake, nil)
+
+	if err := c.Health(context.Background()); err != nil {
+		t.Errorf("Health() error = %v", err)
+	}
+
+	fake.Errors["DescribeMetricMetaList"] = errors.New("boom")
+	if err := c.Health(context.Background()); err == nil {
+		t.Error("Health() error = nil, want server error")
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	fake := clienttest.New()
+	fake.AddDatapoints("cn-hangzhou", "acs_slb_dashboard", "ActiveConnection", datapoints(3)...)
+	fake.Instances["slb"] = []client.Instance{{ID: "lb-1", Region: "cn-hangzhou", Tags: map[string]string{"Team": "infra"}}}
+	recorder := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.RecordDir = dir
+		cfg.MetricPageSize = 2
+	})
+
+	recorded, err := recorder.GetMetricDataInRegion(context.Background(), "cn-hangzhou", "acs_slb_dashboard", "ActiveConnection")
+	if err != nil {
+		t.Fatalf("GetMetricDataInRegion() error = %v", err)
+	}
+	if _, err := recorder.DescribeSLBInstances(context.Background()); err != nil {
+		t.Fatalf("DescribeSLBInstances() error = %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("recorded %d fixtures, want 3", len(files))
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"test-key", "test-secret", "Signature", "AccessKeyId"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("fixture %s contains %q", filepath.Base(file), secret)
+			}
+		}
+	}
+
+	// Replay without credentials, the fake server is no longer used
+	replayer, err := client.NewClient(&config.AlicloudConfig{
+		Region:         "cn-hangzhou",
+		RateLimit:      config.RateLimitConfig{RequestsPerSecond: 1000, Burst: 1000},
+		Retry:          config.RetryConfig{MaxAttempts: 1},
+		Credentials:    config.CredentialsConfig{Type: "access_key"},
+		Endpoints:      map[string]string{"cms": "http://127.0.0.1:1", "slb": "http://127.0.0.1:1"},
+		ReplayDir:      dir,
+		MetricPageSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer replayer.Close()
+
+	replayed, err := replayer.GetMetricDataInRegion(context.Background(), "cn-hangzhou", "acs_slb_dashboard", "ActiveConnection")
+	if err != nil {
+		t.Fatalf("replayed GetMetricDataInRegion() error = %v", err)
+	}
+	if replayed.Datapoints != recorded.Datapoints {
+		t.Errorf("replayed datapoints %s, want %s", replayed.Datapoints, recorded.Datapoints)
+	}
+
+	instances, err := replayer.DescribeSLBInstances(context.Background())
+	if err != nil {
+		t.Fatalf("replayed DescribeSLBInstances() error = %v", err)
+	}
+	if len(instances) != 1 || instances[0].Tags["Team"] != "infra" {
+		t.Errorf("replayed instances %+v, want lb-1 with Team=infra", instances)
+	}
+
+	if _, err := replayer.GetMetricDataInRegion(context.Background(), "cn-hangzhou", "acs_slb_dashboard", "DropConnection"); err == nil {
+		t.Error("GetMetricDataInRegion() of an unrecorded request error = nil, want error")
+	}
+}
+
+func TestGetMetricDataDeduplicatesConcurrentRequests(t *testing.T) {
+	fake := clienttest.New()
+	fake.Delay = 100 * time.Millisecond
+	fake.AddDatapoints("cn-hangzhou", "acs_test", "CpuUsage", datapoints(2)...)
+	c := newTestClient(t, fake, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := c.GetMetricDataInRegion(context.Background(), "cn-hangzhou", "acs_test", "CpuUsage")
+			if err != nil || response.Datapoints == "" {
+				t.Errorf("GetMetricDataInRegion() = %v, %v", response, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := fake.Calls("DescribeMetricLast"); calls != 1 {
+		t.Errorf("got %d DescribeMetricLast requests, want 1", calls)
+	}
+}
+
+func TestSLBTagsServedFromInventory(t *testing.T) {
+	fake := clienttest.New()
+	fake.Instances["slb"] = []client.Instance{{ID: "lb-1", Region: "cn-hangzhou", Tags: map[string]string{"Team": "infra"}}}
+	c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.TagCache = config.TagCacheConfig{TTL: 100 * time.Millisecond, NegativeTTL: time.Millisecond, StaleTTL: time.Hour}
+	})
+
+	tags, err := c.GetSLBInstanceTags(context.Background(), []string{"lb-1"})
+	if err != nil || tags["lb-1"]["Team"] != "infra" {
+		t.Fatalf("GetSLBInstanceTags() = %v, %v, want Team=infra", tags, err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	// Stale tags and unknown load balancers are looked up in the cached inventory
+	for i := 0; i < 3; i++ {
+		tags, err = c.GetSLBInstanceTags(context.Background(), []string{"lb-1", "lb-unknown"})
+		if err != nil || tags["lb-1"]["Team"] != "infra" || len(tags["lb-unknown"]) != 0 {
+			t.Fatalf("GetSLBInstanceTags() = %v, %v, want Team=infra and no tags of lb-unknown", tags, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if calls := fake.Calls("DescribeLoadBalancers"); calls != 1 {
+		t.Errorf("got %d DescribeLoadBalancers requests, want the inventory listed once", calls)
+	}
+}
+
+func TestSLBTagsRefreshedWithInventory(t *testing.T) {
+	fake := clienttest.New()
+	fake.Instances["slb"] = []client.Instance{{ID: "lb-1", Region: "cn-hangzhou", Tags: map[string]string{"Team": "infra"}}}
+	c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.TagCache = config.TagCacheConfig{TTL: 100 * time.Millisecond, StaleTTL: time.Hour}
+		cfg.InventoryRefreshInterval = 50 * time.Millisecond
+	})
+
+	tags, err := c.GetSLBInstanceTags(context.Background(), []string{"lb-1"})
+	if err != nil || tags["lb-1"]["Team"] != "infra" {
+		t.Fatalf("GetSLBInstanceTags() = %v, %v, want Team=infra", tags, err)
+	}
+
+	fake.SetInstances("slb", client.Instance{ID: "lb-1", Region: "cn-hangzhou", Tags: map[string]string{"Team": "platform"}})
+	deadline := time.Now().Add(time.Second)
+	for {
+		tags, _ = c.GetSLBInstanceTags(context.Background(), []string{"lb-1"})
+		if tags["lb-1"]["Team"] == "platform" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tags were not refreshed, got %v", tags)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDescribeInstancesCoalescesListings(t *testing.T) {
+	fake := clienttest.New()
+	fake.Instances["slb"] = []client.Instance{{ID: "lb-1", Region: "cn-hangzhou"}}
+	fake.Delay = 50 * time.Millisecond
+	c := newTestClient(t, fake, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			instances, err := c.DescribeSLBInstances(context.Background())
+			if err != nil || len(instances) != 1 {
+				t.Errorf("DescribeSLBInstances() = %v, %v, want lb-1", instances, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := fake.Calls("DescribeLoadBalancers"); calls != 1 {
+		t.Errorf("got %d DescribeLoadBalancers requests, want concurrent listings to share one", calls)
+	}
+}
+
+func TestSLBTagsFromPaginatedInventory(t *testing.T) {
+	regions := []string{"cn-hangzhou", "cn-shanghai"}
+	fake := clienttest.New(regions...)
+	for _, region := range regions {
+		for i := 0; i < 150; i++ {
+			fake.Instances["slb"] = append(fake.Instances["slb"], client.Instance{
+				ID:     fmt.Sprintf("lb-%s-%d", region, i),
+				Region: region,
+				Tags:   map[string]string{"Index": fmt.Sprint(i)},
+			})
+		}
+	}
+	metrics := client.NewMetrics("alicloud", nil)
+	c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.Regions = regions
+	})
+	c.SetMetrics(metrics)
+
+	ids := []string{"lb-cn-hangzhou-5", "lb-cn-shanghai-149", "lb-unknown"}
+	tags, instanceRegions, err := c.GetSLBInstanceTagsWithRegion(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("GetSLBInstanceTagsWithRegion() error = %v", err)
+	}
+
+	// Load balancers beyond the first page are found
+	if tags["lb-cn-shanghai-149"]["Index"] != "149" || instanceRegions["lb-cn-shanghai-149"] != "cn-shanghai" {
+		t.Errorf("lb-cn-shanghai-149 has tags %v in region %q", tags["lb-cn-shanghai-149"], instanceRegions["lb-cn-shanghai-149"])
+	}
+	if tags["lb-cn-hangzhou-5"]["Index"] != "5" || instanceRegions["lb-cn-hangzhou-5"] != "cn-hangzhou" {
+		t.Errorf("lb-cn-hangzhou-5 has tags %v in region %q", tags["lb-cn-hangzhou-5"], instanceRegions["lb-cn-hangzhou-5"])
+	}
+	if len(tags["lb-unknown"]) != 0 {
+		t.Errorf("lb-unknown has tags %v, want none", tags["lb-unknown"])
+	}
+	if calls := fake.Calls("DescribeLoadBalancers"); calls != 4 {
+		t.Errorf("got %d DescribeLoadBalancers requests, want 4", calls)
+	}
+
+	expected := `
+# HELP alicloud_inventory_instances Number of instances in the last successful inventory listing by service and region.
+# TYPE alicloud_inventory_instances gauge
+alicloud_inventory_instances{region="cn-hangzhou",service="slb"} 150
+alicloud_inventory_instances{region="cn-shanghai",service="slb"} 150
+`
+	if err := testutil.CollectAndCompare(metrics, strings.NewReader(expected), "alicloud_inventory_instances"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInventoryRefreshedInBackground(t *testing.T) {
+	fake := clienttest.New()
+	fake.Instances["slb"] = []client.Instance{{ID: "lb-1", Region: "cn-hangzhou"}}
+	c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.InventoryRefreshInterval = 50 * time.Millisecond
+	})
+
+	if _, err := c.DescribeSLBInstances(context.Background()); err != nil {
+		t.Fatalf("DescribeSLBInstances() error = %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for fake.Calls("DescribeLoadBalancers") < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d DescribeLoadBalancers requests, want the inventory refreshed twice", fake.Calls("DescribeLoadBalancers"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Refreshed inventories are served from the cache
+	calls := fake.Calls("DescribeLoadBalancers")
+	instances, err := c.DescribeSLBInstances(context.Background())
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("DescribeSLBInstances() = %v, %v, want lb-1", instances, err)
+	}
+	if got := fake.Calls("DescribeLoadBalancers"); got > calls+1 {
+		t.Errorf("DescribeSLBInstances() made %d requests, want the cached inventory", got-calls)
+	}
+}
+
+func TestDescribeRDSInstancesLooksUpTagsInListedRegion(t *testing.T) {
+	regions := []string{"cn-beijing", "cn-hangzhou", "cn-shanghai"}
+	fake := clienttest.New(regions...)
+	for _, region := range regions {
+		fake.Instances["rds"] = append(fake.Instances["rds"],
+			client.Instance{ID: "rm-tagged-" + region, Region: region, Tags: map[string]string{"Team": "db"}},
+			client.Instance{ID: "rm-untagged-" + region, Region: region},
+		)
+	}
+	c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.Regions = regions
+	})
+
+	instances, err := c.DescribeRDSInstances(context.Background())
+	if err != nil {
+		t.Fatalf("DescribeRDSInstances() error = %v", err)
+	}
+	if len(instances) != 6 {
+		t.Fatalf("got %d instances, want 6", len(instances))
+	}
+	for _, instance := range instances {
+		tagged := strings.HasPrefix(instance.ID, "rm-tagged-")
+		if tagged && instance.Tags["Team"] != "db" || !tagged && len(instance.Tags) != 0 {
+			t.Errorf("instance %s has tags %v", instance.ID, instance.Tags)
+		}
+	}
+	if calls := fake.Calls("ListTagResources"); calls != len(regions) {
+		t.Errorf("got %d ListTagResources requests, want one per region", calls)
+	}
+
+	// Tags of listed instances, tagged or not, are taken from the inventory
+	tags, err := c.GetRDSInstanceTags(context.Background(), "cn-shanghai", []string{"rm-tagged-cn-shanghai"})
+	if err != nil || tags["rm-tagged-cn-shanghai"]["Team"] != "db" {
+		t.Fatalf("GetRDSInstanceTags() = %v, %v, want Team=db", tags, err)
+	}
+	tags, err = c.GetRDSInstanceTags(context.Background(), "cn-beijing", []string{"rm-untagged-cn-beijing"})
+	if err != nil || len(tags["rm-untagged-cn-beijing"]) != 0 {
+		t.Fatalf("GetRDSInstanceTags() = %v, %v, want empty tags", tags, err)
+	}
+	if calls := fake.Calls("ListTagResources"); calls != len(regions) {
+		t.Errorf("got %d ListTagResources requests, want the inventory tags", calls)
+	}
+}
+
+func TestDescribeRDSInstancesCachedWithPartialTags(t *testing.T) {
+	fake := clienttest.New()
+	fake.Instances["rds"] = []client.Instance{{ID: "rm-1", Region: "cn-hangzhou", Tags: map[string]string{"Team": "db"}}}
+	fake.Errors["ListTagResources"] = &clienttest.APIError{Status: 400, Code: "InvalidParameter"}
+	c := newTestClient(t, fake, nil)
+
+	instances, err := c.DescribeRDSInstances(context.Background())
+	if err == nil {
+		t.Error("DescribeRDSInstances() error = nil, want the tag lookup error")
+	}
+	if len(instances) != 1 {
+		t.Fatalf("got %d instances, want the listed instance", len(instances))
+	}
+
+	if _, err := c.DescribeRDSInstances(context.Background()); err != nil {
+		t.Errorf("DescribeRDSInstances() from the cache error = %v", err)
+	}
+	if calls := fake.Calls("DescribeDBInstances"); calls != 1 {
+		t.Errorf("got %d DescribeDBInstances requests, want the listing cached", calls)
+	}
+}
+
+func TestRDSTagsOfUntaggedInstancesCached(t *testing.T) {
+	fake := clienttest.New()
+	fake.Instances["rds"] = []client.Instance{{ID: "rm-untagged", Region: "cn-hangzhou"}}
+	fake.Errors["ListTagResources"] = &clienttest.APIError{Status: 400, Code: "InvalidParameter", Times: 1}
+	c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.TagCache = config.TagCacheConfig{TTL: time.Minute, NegativeTTL: time.Millisecond}
+	})
+
+	// The listing is cached without the tags that failed to resolve
+	if _, err := c.DescribeRDSInstances(context.Background()); err == nil {
+		t.Fatal("DescribeRDSInstances() error = nil, want the tag lookup error")
+	}
+
+	// The tags are looked up, the untagged instance is absent from ListTagResources but listed in
+	// the inventory
+	tags, err := c.GetRDSInstanceTags(context.Background(), "cn-hangzhou", []string{"rm-untagged", "rm-unknown"})
+	if err != nil || len(tags["rm-untagged"]) != 0 || len(tags["rm-unknown"]) != 0 {
+		t.Fatalf("GetRDSInstanceTags() = %v, %v, want empty tags", tags, err)
+	}
+	if calls := fake.Calls("ListTagResources"); calls != 2 {
+		t.Fatalf("got %d ListTagResources requests, want 2", calls)
+	}
+
+	// The untagged instance is cached for the TTL rather than the negative TTL
+	time.Sleep(10 * time.Millisecond)
+	if _, err := c.GetRDSInstanceTags(context.Background(), "cn-hangzhou", []string{"rm-untagged"}); err != nil {
+		t.Fatalf("GetRDSInstanceTags() error = %v", err)
+	}
+	if calls := fake.Calls("ListTagResources"); calls != 2 {
+		t.Errorf("got %d ListTagResources requests, want the untagged instance cached", calls)
+	}
+	if _, err := c.GetRDSInstanceTags(context.Background(), "cn-hangzhou", []string{"rm-unknown"}); err != nil {
+		t.Fatalf("GetRDSInstanceTags() error = %v", err)
+	}
+	if calls := fake.Calls("ListTagResources"); calls != 3 {
+		t.Errorf("got %d ListTagResources requests, want the unknown instance looked up again", calls)
+	}
+}
+
+func TestInventoryListingsLeaveTagCache(t *testing.T) {
+	fake := clienttest.New()
+	for i := 0; i < 5; i++ {
+		fake.Instances["slb"] = append(fake.Instances["slb"], client.Instance{ID: fmt.Sprintf("lb-%d", i), Region: "cn-hangzhou"})
+		fake.Instances["rds"] = append(fake.Instances["rds"], client.Instance{ID: fmt.Sprintf("rm-%d", i), Region: "cn-hangzhou"})
+	}
+	metrics := client.NewMetrics("alicloud", nil)
+	c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.TagCache = config.TagCacheConfig{TTL: time.Minute, NegativeTTL: time.Minute, MaxEntries: 2}
+	})
+	c.SetMetrics(metrics)
+
+	if _, err := c.GetSLBInstanceTags(context.Background(), []string{"lb-0"}); err != nil {
+		t.Fatalf("GetSLBInstanceTags() error = %v", err)
+	}
+	if _, err := c.GetRDSInstanceTags(context.Background(), "cn-hangzhou", []string{"rm-0"}); err != nil {
+		t.Fatalf("GetRDSInstanceTags() error = %v", err)
+	}
+
+	// Listing every instance keeps the tags in the inventory, not in the bounded tag cache
+	if _, err := c.DescribeSLBInstances(context.Background()); err != nil {
+		t.Fatalf("DescribeSLBInstances() error = %v", err)
+	}
+	if _, err := c.DescribeRDSInstances(context.Background()); err != nil {
+		t.Fatalf("DescribeRDSInstances() error = %v", err)
+	}
+	expected := `
+# HELP alicloud_tag_cache_evictions_total Total number of least recently used instances evicted from the tag cache.
+# TYPE alicloud_tag_cache_evictions_total counter
+alicloud_tag_cache_evictions_total 0
+`
+	if err := testutil.CollectAndCompare(metrics, strings.NewReader(expected), "alicloud_tag_cache_evictions_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRDSTagsLookedUpInTheirRegion(t *testing.T) {
+	regions := []string{"cn-beijing", "cn-hangzhou", "cn-shanghai"}
+	fake := clienttest.New(regions...)
+	ids := make([]string, 0, 60)
+	for i := 0; i < 60; i++ {
+		id := fmt.Sprintf("rm-%d", i)
+		ids = append(ids, id)
+		fake.Instances["rds"] = append(fake.Instances["rds"], client.Instance{ID: id, Region: "cn-shanghai", Tags: map[string]string{"Team": "db"}})
+	}
+	c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.Regions = regions
+	})
+
+	tags, err := c.GetRDSInstanceTags(context.Background(), "cn-shanghai", ids)
+	if err != nil {
+		t.Fatalf("GetRDSInstanceTags() error = %v", err)
+	}
+	for _, id := range ids {
+		if tags[id]["Team"] != "db" {
+			t.Errorf("instance %s has tags %v, want Team=db", id, tags[id])
+		}
+	}
+
+	// Only the region of the instances is queried, in batches of 50
+	if calls := fake.Calls("ListTagResources"); calls != 2 {
+		t.Errorf("got %d ListTagResources requests, want 2 batches in one region", calls)
+	}
+}
+
+func TestDescribeMetricMetaCached(t *testing.T) {
+	fake := clienttest.New()
+	fake.Metas["acs_test"] = []client.MetricMeta{{MetricName: "CpuUsage", Description: "CPU usage", Unit: "%"}}
+	c := newTestClient(t, fake, nil)
+
+	for i := 0; i < 2; i++ {
+		metas, err := c.DescribeMetricMeta(context.Background(), "acs_test")
+		if err != nil || len(metas) != 1 || metas[0].Description != "CPU usage" {
+			t.Fatalf("DescribeMetricMeta() = %+v, %v, want CpuUsage", metas, err)
+		}
+	}
+	if calls := fake.Calls("DescribeMetricMetaList"); calls != 1 {
+		t.Errorf("got %d DescribeMetricMetaList requests, want the metadata cached", calls)
+	}
+
+	// Failures are returned and not cached
+	fake.Errors["acs_failing"] = &clienttest.APIError{Status: 400, Code: "InvalidParameter", Times: 1}
+	if _, err := c.DescribeMetricMeta(context.Background(), "acs_failing"); err == nil {
+		t.Error("DescribeMetricMeta() error = nil, want the API error")
+	}
+	if _, err := c.DescribeMetricMeta(context.Background(), "acs_failing"); err != nil {
+		t.Errorf("DescribeMetricMeta() after the failure error = %v", err)
+	}
+}
+
+func TestMetaCacheExpires(t *testing.T) {
+	cache := client.NewMetaCache(20 * time.Millisecond)
+	cache.Set("acs_test", []client.MetricMeta{{MetricName: "CpuUsage"}})
+
+	if metas, found := cache.Get("acs_test"); !found || len(metas) != 1 {
+		t.Fatalf("Get() = %v, %t, want the cached metadata", metas, found)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, found := cache.Get("acs_test"); found {
+		t.Error("Get() found the metadata after the TTL")
+	}
+}
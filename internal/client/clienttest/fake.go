@@ -0,0 +1,284 @@
+// Package clienttest provides fakes of the Alicloud API for tests: Fake implements client.API
+// in memory, and NewServer serves the same data over the CMS and SLB RPC APIs so the real
+// client can be pointed at it through alicloud.endpoints.
+package clienttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"alicloud-exporter/internal/client"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/cms"
+)
+
+// Datapoint is a CMS datapoint, such as {"instanceId": "lb-1", "timestamp": 1700000000000, "Average": 1.5}
+type Datapoint map[string]interface{}
+
+// Fake is an in-memory client.API. Its fields may be set directly before use, datapoints are
+// added with AddDatapoints.
+type Fake struct {
+	// Regions are the regions metrics are collected from, defaulting to cn-hangzhou
+	Regions []string
+	// Metas lists the metrics of each namespace
+	Metas map[string][]client.MetricMeta
+	// Instances lists the instances of each service (slb, rds or redis), with their tags
+	Instances map[string][]client.Instance
+	// Errors fails the calls of a metric ("namespace/metric") or a method ("DescribeSLBInstances"),
+	// an *APIError sets the HTTP status and error code returned by NewServer
+	Errors map[string]error
+	// Delay delays the responses of NewServer, letting concurrent requests overlap
+	Delay time.Duration
+
+	datapoints map[string][]Datapoint
+	calls      map[string]int
+	failed     map[string]int
+	mu         sync.Mutex
+}
+
+// APIError is an Alicloud API error. NewServer answers with its HTTP status and error code,
+// and Times limits it to the first calls, after which calls succeed again.
+type APIError struct {
+	Status int
+	Code   string
+	Times  int
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Code, e.Status)
+}
+
+var _ client.API = (*Fake)(nil)
+
+// New creates an empty fake collecting from the given regions
+func New(regions ...string) *Fake {
+	if len(regions) == 0 {
+		regions = []string{"cn-hangzhou"}
+	}
+	return &Fake{
+		Regions:    regions,
+		Metas:      make(map[string][]client.MetricMeta),
+		Instances:  make(map[string][]client.Instance),
+		Errors:     make(map[string]error),
+		datapoints: make(map[string][]Datapoint),
+		calls:      make(map[string]int),
+		failed:     make(map[string]int),
+	}
+}
+
+// AddDatapoints adds datapoints of a metric in a region
+func (f *Fake) AddDatapoints(region, namespace, metricName string, datapoints ...Datapoint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := datapointKey(region, namespace, metricName)
+	f.datapoints[key] = append(f.datapoints[key], datapoints...)
+}
+
+// SetInstances replaces the instances of a service while the fake is in use
+func (f *Fake) SetInstances(service string, instances ...client.Instance) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Instances[service] = instances
+}
+
+// Calls returns how many times a method was called
+func (f *Fake) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// Datapoints returns the datapoints of a metric in a region matching any of the dimension
+// filters, or all of them without filters
+func (f *Fake) Datapoints(region, namespace, metricName string, dimensions []client.Dimension) []Datapoint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var matched []Datapoint
+	for _, datapoint := range f.datapoints[datapointKey(region, namespace, metricName)] {
+		if matchesAny(datapoint, dimensions) {
+			matched = append(matched, datapoint)
+		}
+	}
+	return matched
+}
+
+// GetMetricDataInRegionWithDimensions implements client.MetricAPI
+func (f *Fake) GetMetricDataInRegionWithDimensions(ctx context.Context, region, namespace, metricName string, dimensions []client.Dimension) (*cms.DescribeMetricLastResponse, error) {
+	if err := f.call("GetMetricDataInRegionWithDimensions", namespace+"/"+metricName); err != nil {
+		return nil, err
+	}
+
+	response := &cms.DescribeMetricLastResponse{Code: "200", Success: true, Period: "60"}
+	datapoints := f.Datapoints(region, namespace, metricName, dimensions)
+	if len(datapoints) == 0 {
+		return response, nil
+	}
+
+	data, err := json.Marshal(datapoints)
+	if err != nil {
+		return nil, err
+	}
+	response.Datapoints = string(data)
+	return response, nil
+}
+
+// DescribeMetricMeta implements client.MetricAPI
+func (f *Fake) DescribeMetricMeta(ctx context.Context, namespace string) ([]client.MetricMeta, error) {
+	if err := f.call("DescribeMetricMeta", namespace); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	metas, found := f.Metas[namespace]
+	if !found {
+		return nil, fmt.Errorf("unknown namespace: %s", namespace)
+	}
+	return metas, nil
+}
+
+// GetRegions implements client.MetricAPI
+func (f *Fake) GetRegions() []string {
+	regions := append([]string{}, f.Regions...)
+	sort.Strings(regions)
+	return regions
+}
+
+// DescribeSLBInstances implements client.DescribeAPI
+func (f *Fake) DescribeSLBInstances(ctx context.Context) ([]client.Instance, error) {
+	return f.instances("DescribeSLBInstances", "slb")
+}
+
+// DescribeRDSInstances implements client.DescribeAPI
+func (f *Fake) DescribeRDSInstances(ctx context.Context) ([]client.Instance, error) {
+	return f.instances("DescribeRDSInstances", "rds")
+}
+
+// DescribeRedisInstances implements client.DescribeAPI
+func (f *Fake) DescribeRedisInstances(ctx context.Context) ([]client.Instance, error) {
+	return f.instances("DescribeRedisInstances", "redis")
+}
+
+// GetSLBInstanceTags implements client.DescribeAPI
+func (f *Fake) GetSLBInstanceTags(ctx context.Context, instanceIDs []string) (map[string]map[string]string, error) {
+	return f.tags("GetSLBInstanceTags", "slb", "", instanceIDs)
+}
+
+// GetRDSInstanceTags implements client.DescribeAPI
+func (f *Fake) GetRDSInstanceTags(ctx context.Context, region string, instanceIDs []string) (map[string]map[string]string, error) {
+	return f.tags("GetRDSInstanceTags", "rds", region, instanceIDs)
+}
+
+// GetRedisInstanceTags implements client.DescribeAPI
+func (f *Fake) GetRedisInstanceTags(ctx context.Context, region string, instanceIDs []string) (map[string]map[string]string, error) {
+	return f.tags("GetRedisInstanceTags", "redis", region, instanceIDs)
+}
+
+// Health implements client.HealthAPI
+func (f *Fake) Health(ctx context.Context) error {
+	return f.call("Health", "")
+}
+
+// call records a call of a method and returns the error configured for it or its target
+func (f *Fake) call(method, target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls[method]++
+	if err := f.failure(method); err != nil {
+		return err
+	}
+	if target != "" {
+		return f.failure(target)
+	}
+	return nil
+}
+
+// failure returns the error configured for a method or target, if it hasn't failed its number of
+// calls yet. The caller must hold f.mu.
+func (f *Fake) failure(key string) error {
+	err, found := f.Errors[key]
+	if !found {
+		return nil
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Times > 0 {
+		if f.failed[key] >= apiErr.Times {
+			return nil
+		}
+		f.failed[key]++
+	}
+	return err
+}
+
+// instances returns a copy of the instances of a service
+func (f *Fake) instances(method, service string) ([]client.Instance, error) {
+	if err := f.call(method, ""); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]client.Instance{}, f.Instances[service]...), nil
+}
+
+// tags returns the tags of the requested instances of a service in region, or in any region if
+// it is empty, with empty tags for unknown instances
+func (f *Fake) tags(method, service, region string, instanceIDs []string) (map[string]map[string]string, error) {
+	if err := f.call(method, ""); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	byID := make(map[string]map[string]string, len(f.Instances[service]))
+	for _, instance := range f.Instances[service] {
+		if region == "" || instance.Region == region {
+			byID[instance.ID] = instance.Tags
+		}
+	}
+
+	tags := make(map[string]map[string]string, len(instanceIDs))
+	for _, id := range instanceIDs {
+		tags[id] = make(map[string]string)
+		for key, value := range byID[id] {
+			tags[id][key] = value
+		}
+	}
+	return tags, nil
+}
+
+// datapointKey returns the key datapoints of a metric in a region are stored under
+func datapointKey(region, namespace, metricName string) string {
+	return region + "/" + namespace + "/" + metricName
+}
+
+// matchesAny reports whether a datapoint matches any of the dimension filters
+func matchesAny(datapoint Datapoint, dimensions []client.Dimension) bool {
+	if len(dimensions) == 0 {
+		return true
+	}
+	for _, dimension := range dimensions {
+		matched := true
+		for key, value := range dimension {
+			if fmt.Sprint(datapoint[key]) != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,313 @@
+package clienttest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"alicloud-exporter/internal/client"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/cms"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/rds"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
+)
+
+// defaultMetricPageSize is the DescribeMetricLast page size of requests without a Length
+const defaultMetricPageSize = 1000
+
+// NewServer starts a fake CMS, SLB and RDS endpoint serving the data of a fake. It answers the
+// DescribeMetricLast, DescribeMetricMetaList, DescribeLoadBalancers, DescribeDBInstances and
+// ListTagResources RPC APIs, paginated like Alicloud, without checking signatures. Errors configured for an action (such as
+// "DescribeLoadBalancers") or a metric ("namespace/metric") are returned as server errors, with
+// the HTTP status and error code of an *APIError.
+func NewServer(f *Fake) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidParameter", err.Error())
+			return
+		}
+		time.Sleep(f.Delay)
+
+		switch action := r.Form.Get("Action"); action {
+		case "DescribeMetricLast":
+			f.serveMetricLast(w, r)
+		case "DescribeMetricMetaList":
+			f.serveMetricMetaList(w, r)
+		case "DescribeLoadBalancers":
+			f.serveLoadBalancers(w, r)
+		case "DescribeDBInstances":
+			f.serveDBInstances(w, r)
+		case "ListTagResources":
+			f.serveTagResources(w, r)
+		default:
+			writeError(w, http.StatusBadRequest, "InvalidAction.NotFound", fmt.Sprintf("unsupported action %q", action))
+		}
+	}))
+}
+
+// Endpoints returns the alicloud.endpoints configuration pointing CMS, SLB and RDS at a fake server
+func Endpoints(server *httptest.Server) map[string]string {
+	return map[string]string{
+		"cms": server.URL,
+		"slb": server.URL,
+		"rds": server.URL,
+	}
+}
+
+// serveMetricLast answers DescribeMetricLast, using NextToken as the offset of the next page
+func (f *Fake) serveMetricLast(w http.ResponseWriter, r *http.Request) {
+	namespace, metricName := r.Form.Get("Namespace"), r.Form.Get("MetricName")
+	if err := f.call("DescribeMetricLast", namespace+"/"+metricName); err != nil {
+		writeCallError(w, err)
+		return
+	}
+
+	var dimensions []client.Dimension
+	if raw := r.Form.Get("Dimensions"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &dimensions); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidParameter.Dimensions", err.Error())
+			return
+		}
+	}
+
+	pageSize := intParam(r, "Length", defaultMetricPageSize)
+	offset := intParam(r, "NextToken", 0)
+	datapoints := f.Datapoints(r.Form.Get("RegionId"), namespace, metricName, dimensions)
+	start, end := paginate(len(datapoints), offset, pageSize)
+
+	response := cms.DescribeMetricLastResponse{
+		Code:      "200",
+		Success:   true,
+		Period:    "60",
+		RequestId: "fake",
+	}
+	if end < len(datapoints) {
+		response.NextToken = strconv.Itoa(end)
+	}
+	if len(datapoints) > 0 {
+		data, err := json.Marshal(datapoints[start:end])
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		response.Datapoints = string(data)
+	}
+	writeJSON(w, response)
+}
+
+// serveMetricMetaList answers DescribeMetricMetaList, unknown namespaces have no metrics
+func (f *Fake) serveMetricMetaList(w http.ResponseWriter, r *http.Request) {
+	namespace := r.Form.Get("Namespace")
+	if err := f.call("DescribeMetricMetaList", namespace); err != nil {
+		writeCallError(w, err)
+		return
+	}
+
+	f.mu.Lock()
+	metas := f.Metas[namespace]
+	f.mu.Unlock()
+
+	pageNumber, pageSize := intParam(r, "PageNumber", 1), intParam(r, "PageSize", 30)
+	start, end := paginate(len(metas), (pageNumber-1)*pageSize, pageSize)
+	resources := make([]cms.Resource, 0, end-start)
+	for _, meta := range metas[start:end] {
+		resources = append(resources, cms.Resource{
+			Namespace:   namespace,
+			MetricName:  meta.MetricName,
+			Description: meta.Description,
+			Unit:        meta.Unit,
+			Periods:     strings.Join(meta.Periods, ","),
+			Dimensions:  strings.Join(meta.Dimensions, ","),
+			Statistics:  strings.Join(meta.Statistics, ","),
+		})
+	}
+
+	writeJSON(w, cms.DescribeMetricMetaListResponse{
+		Code:       "200",
+		Success:    true,
+		RequestId:  "fake",
+		TotalCount: strconv.Itoa(len(metas)),
+		Resources:  cms.ResourcesInDescribeMetricMetaList{Resource: resources},
+	})
+}
+
+// serveLoadBalancers answers DescribeLoadBalancers with the SLB instances of the region
+func (f *Fake) serveLoadBalancers(w http.ResponseWriter, r *http.Request) {
+	if err := f.call("DescribeLoadBalancers", ""); err != nil {
+		writeCallError(w, err)
+		return
+	}
+
+	instances := f.regionInstances("slb", r.Form.Get("RegionId"))
+	pageNumber, pageSize := intParam(r, "PageNumber", 1), intParam(r, "PageSize", 10)
+	start, end := paginate(len(instances), (pageNumber-1)*pageSize, pageSize)
+	loadBalancers := make([]slb.LoadBalancer, 0, end-start)
+	for _, instance := range instances[start:end] {
+		tags := make([]slb.Tag, 0, len(instance.Tags))
+		for key, value := range instance.Tags {
+			tags = append(tags, slb.Tag{TagKey: key, TagValue: value})
+		}
+		loadBalancers = append(loadBalancers, slb.LoadBalancer{
+			LoadBalancerId:     instance.ID,
+			LoadBalancerName:   instance.Name,
+			RegionId:           instance.Region,
+			LoadBalancerSpec:   instance.Spec,
+			NetworkType:        instance.NetworkType,
+			VpcId:              instance.VpcID,
+			MasterZoneId:       instance.Zone,
+			PayType:            instance.ChargeType,
+			LoadBalancerStatus: instance.Status,
+			CreateTime:         instance.CreateTime,
+			Tags:               slb.TagsInDescribeLoadBalancers{Tag: tags},
+		})
+	}
+
+	writeJSON(w, slb.DescribeLoadBalancersResponse{
+		RequestId:     "fake",
+		PageNumber:    pageNumber,
+		PageSize:      pageSize,
+		TotalCount:    len(instances),
+		LoadBalancers: slb.LoadBalancers{LoadBalancer: loadBalancers},
+	})
+}
+
+// serveDBInstances answers DescribeDBInstances with the RDS instances of the region, without tags
+func (f *Fake) serveDBInstances(w http.ResponseWriter, r *http.Request) {
+	if err := f.call("DescribeDBInstances", ""); err != nil {
+		writeCallError(w, err)
+		return
+	}
+
+	instances := f.regionInstances("rds", r.Form.Get("RegionId"))
+	pageNumber, pageSize := intParam(r, "PageNumber", 1), intParam(r, "PageSize", 30)
+	start, end := paginate(len(instances), (pageNumber-1)*pageSize, pageSize)
+	dbInstances := make([]rds.DBInstance, 0, end-start)
+	for _, instance := range instances[start:end] {
+		dbInstances = append(dbInstances, rds.DBInstance{
+			DBInstanceId:          instance.ID,
+			DBInstanceDescription: instance.Name,
+			RegionId:              instance.Region,
+			DBInstanceClass:       instance.Spec,
+			Engine:                instance.Engine,
+			EngineVersion:         instance.EngineVersion,
+			InstanceNetworkType:   instance.NetworkType,
+			VpcId:                 instance.VpcID,
+			ZoneId:                instance.Zone,
+			PayType:               instance.ChargeType,
+			DBInstanceStatus:      instance.Status,
+			CreateTime:            instance.CreateTime,
+			ExpireTime:            instance.ExpireTime,
+		})
+	}
+
+	writeJSON(w, rds.DescribeDBInstancesResponse{
+		RequestId:        "fake",
+		PageNumber:       pageNumber,
+		PageRecordCount:  len(dbInstances),
+		TotalRecordCount: len(instances),
+		Items:            rds.ItemsInDescribeDBInstances{DBInstance: dbInstances},
+	})
+}
+
+// serveTagResources answers ListTagResources with the tags of the requested RDS instances of the
+// region, untagged and unknown instances are left out like Alicloud does
+func (f *Fake) serveTagResources(w http.ResponseWriter, r *http.Request) {
+	if err := f.call("ListTagResources", ""); err != nil {
+		writeCallError(w, err)
+		return
+	}
+
+	requested := make(map[string]bool)
+	for i := 1; r.Form.Get(fmt.Sprintf("ResourceId.%d", i)) != ""; i++ {
+		requested[r.Form.Get(fmt.Sprintf("ResourceId.%d", i))] = true
+	}
+
+	var resources []rds.TagResource
+	for _, instance := range f.regionInstances("rds", r.Form.Get("RegionId")) {
+		if !requested[instance.ID] {
+			continue
+		}
+		for key, value := range instance.Tags {
+			resources = append(resources, rds.TagResource{
+				ResourceId:   instance.ID,
+				ResourceType: "ALIYUN::RDS::INSTANCE",
+				TagKey:       key,
+				TagValue:     value,
+			})
+		}
+	}
+
+	writeJSON(w, rds.ListTagResourcesResponse{
+		RequestId:    "fake",
+		TagResources: rds.TagResources{TagResource: resources},
+	})
+}
+
+// regionInstances returns the instances of a service in a region
+func (f *Fake) regionInstances(service, region string) []client.Instance {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var instances []client.Instance
+	for _, instance := range f.Instances[service] {
+		if instance.Region == region {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+// intParam returns an integer request parameter, or fallback when it is missing or invalid
+func intParam(r *http.Request, name string, fallback int) int {
+	value, err := strconv.Atoi(r.Form.Get(name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// paginate returns the bounds of a page of total items starting at offset
+func paginate(total, offset, pageSize int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := offset + pageSize
+	if pageSize <= 0 || end > total {
+		end = total
+	}
+	return offset, end
+}
+
+// writeJSON writes a successful API response
+func writeJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// writeCallError writes the error configured for a call, an internal error unless it is an *APIError
+func writeCallError(w http.ResponseWriter, err error) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		writeError(w, apiErr.Status, apiErr.Code, apiErr.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+}
+
+// writeError writes an API error in the format of Alicloud RPC APIs
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"Code":      code,
+		"Message":   message,
+		"RequestId": "fake",
+	})
+}
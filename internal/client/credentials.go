@@ -0,0 +1,288 @@
+package client
+
+import (
+	"alicloud-exporter/internal/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// defaultMetadataEndpoint is the ECS instance metadata service
+	defaultMetadataEndpoint = "http://100.100.100.200"
+
+	// credentialsRefreshWindow is how long before expiry temporary credentials are refreshed
+	credentialsRefreshWindow = 3 * time.Minute
+
+	// metadataTokenTTL is the lifetime requested for IMDSv2 metadata tokens
+	metadataTokenTTL = "21600"
+)
+
+// NewCredentialsProvider creates the credentials provider selected by the Alicloud configuration
+func NewCredentialsProvider(cfg *config.AlicloudConfig) (credentials.CredentialsProvider, error) {
+	creds := cfg.Credentials
+
+	// Replayed requests are answered from fixtures, placeholder credentials only sign them
+	if cfg.ReplayDir != "" {
+		return credentials.NewStaticAKCredentialsProvider("replay", "replay"), nil
+	}
+
+	switch creds.Type {
+	case "", "access_key":
+		return credentials.NewStaticAKCredentialsProviderBuilder().
+			WithAccessKeyId(cfg.AccessKeyID).
+			WithAccessKeySecret(cfg.AccessKeySecret).
+			Build()
+
+	case "sts_token":
+		return credentials.NewStaticSTSCredentialsProviderBuilder().
+			WithAccessKeyId(cfg.AccessKeyID).
+			WithAccessKeySecret(cfg.AccessKeySecret).
+			WithSecurityToken(creds.SecurityToken).
+			Build()
+
+	case "ecs_ram_role":
+		return NewECSMetadataProvider(creds.MetadataEndpoint, creds.RoleName), nil
+
+	case "ram_role_arn":
+		// Assume the role using the static access key, or the ECS instance role when no key is configured
+		var source credentials.CredentialsProvider
+		if cfg.AccessKeyID != "" {
+			source = credentials.NewStaticAKCredentialsProvider(cfg.AccessKeyID, cfg.AccessKeySecret)
+		} else {
+			source = NewECSMetadataProvider(creds.MetadataEndpoint, creds.RoleName)
+		}
+		return credentials.NewRAMRoleARNCredentialsProviderBuilder().
+			WithCredentialsProvider(source).
+			WithRoleArn(creds.RoleArn).
+			WithRoleSessionName(creds.RoleSessionName).
+			WithExternalId(creds.ExternalID).
+			WithPolicy(creds.Policy).
+			WithDurationSeconds(creds.DurationSeconds).
+			WithStsRegion(creds.STSRegion).
+			WithStsEndpoint(creds.STSEndpoint).
+			Build()
+
+	case "oidc":
+		return credentials.NewOIDCCredentialsProviderBuilder().
+			WithRoleArn(creds.RoleArn).
+			WithOIDCProviderARN(creds.OIDCProviderArn).
+			WithOIDCTokenFilePath(creds.OIDCTokenFile).
+			WithRoleSessionName(creds.RoleSessionName).
+			WithDurationSeconds(creds.DurationSeconds).
+			WithPolicy(creds.Policy).
+			WithStsRegion(creds.STSRegion).
+			WithSTSEndpoint(creds.STSEndpoint).
+			Build()
+
+	case "profile":
+		return newProfileProvider(creds.File, creds.Profile, creds.MetadataEndpoint)
+
+	default:
+		return nil, fmt.Errorf("unsupported credentials type: %s", creds.Type)
+	}
+}
+
+// newProfileProvider creates the provider of a profile of an Alibaba Cloud credentials file. The
+// file is read here rather than by the SDK, which only locates it through the process-wide
+// ALIBABA_CLOUD_CREDENTIALS_FILE variable and so can't give each account its own file.
+func newProfileProvider(file, profile, metadataEndpoint string) (credentials.CredentialsProvider, error) {
+	if file == "" {
+		file = os.Getenv("ALIBABA_CLOUD_CREDENTIALS_FILE")
+	}
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate credentials file: %w", err)
+		}
+		file = filepath.Join(home, ".alibabacloud", "credentials")
+	}
+	if profile == "" {
+		profile = os.Getenv("ALIBABA_CLOUD_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	profiles, err := ini.Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	section, err := profiles.GetSection(profile)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s not found in %s", profile, file)
+	}
+	value := func(key string) string {
+		return section.Key(key).String()
+	}
+
+	switch profileType := value("type"); profileType {
+	case "access_key":
+		return credentials.NewStaticAKCredentialsProviderBuilder().
+			WithAccessKeyId(value("access_key_id")).
+			WithAccessKeySecret(value("access_key_secret")).
+			Build()
+
+	case "ecs_ram_role":
+		return NewECSMetadataProvider(metadataEndpoint, value("role_name")), nil
+
+	case "ram_role_arn":
+		return credentials.NewRAMRoleARNCredentialsProviderBuilder().
+			WithAccessKeyId(value("access_key_id")).
+			WithAccessKeySecret(value("access_key_secret")).
+			WithRoleArn(value("role_arn")).
+			WithRoleSessionName(value("role_session_name")).
+			WithPolicy(value("policy")).
+			Build()
+
+	default:
+		return nil, fmt.Errorf("unsupported credentials type %q in profile %s of %s", profileType, profile, file)
+	}
+}
+
+// ECSMetadataProvider obtains the STS credentials of the ECS instance RAM role from the
+// metadata service and refreshes them shortly before they expire
+type ECSMetadataProvider struct {
+	endpoint    string
+	roleName    string
+	httpClient  *http.Client
+	credentials *credentials.Credentials
+	expiration  time.Time
+	mu          sync.Mutex
+}
+
+// ecsRoleCredentials is the metadata service response for a RAM role
+type ecsRoleCredentials struct {
+	Code            string `json:"Code"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	AccessKeySecret string `json:"AccessKeySecret"`
+	SecurityToken   string `json:"SecurityToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+// NewECSMetadataProvider creates a provider for the given metadata endpoint, an empty role name is discovered
+func NewECSMetadataProvider(endpoint, roleName string) *ECSMetadataProvider {
+	if endpoint == "" {
+		endpoint = defaultMetadataEndpoint
+	}
+
+	return &ECSMetadataProvider{
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		roleName:   roleName,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// GetCredentials returns cached credentials, refreshing them when they are about to expire
+func (p *ECSMetadataProvider) GetCredentials() (*credentials.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.credentials != nil && time.Until(p.expiration) > credentialsRefreshWindow {
+		return p.credentials, nil
+	}
+
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+	return p.credentials, nil
+}
+
+// GetProviderName returns the provider name
+func (p *ECSMetadataProvider) GetProviderName() string {
+	return "ecs_ram_role"
+}
+
+// refresh fetches new credentials from the metadata service
+func (p *ECSMetadataProvider) refresh() error {
+	// IMDSv2 token is optional, fall back to unauthenticated requests when unavailable
+	token, _ := p.metadataToken()
+
+	if p.roleName == "" {
+		roleName, err := p.get("/latest/meta-data/ram/security-credentials/", token)
+		if err != nil {
+			return fmt.Errorf("failed to discover ECS RAM role: %w", err)
+		}
+		p.roleName = strings.TrimSpace(roleName)
+	}
+
+	body, err := p.get("/latest/meta-data/ram/security-credentials/"+p.roleName, token)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials for ECS RAM role %s: %w", p.roleName, err)
+	}
+
+	var roleCredentials ecsRoleCredentials
+	if err := json.Unmarshal([]byte(body), &roleCredentials); err != nil {
+		return fmt.Errorf("failed to decode credentials for ECS RAM role %s: %w", p.roleName, err)
+	}
+	if roleCredentials.Code != "Success" {
+		return fmt.Errorf("metadata service returned code %q for ECS RAM role %s", roleCredentials.Code, p.roleName)
+	}
+
+	expiration, err := time.Parse("2006-01-02T15:04:05Z", roleCredentials.Expiration)
+	if err != nil {
+		return fmt.Errorf("invalid expiration for ECS RAM role %s: %w", p.roleName, err)
+	}
+
+	p.credentials = &credentials.Credentials{
+		AccessKeyId:     roleCredentials.AccessKeyID,
+		AccessKeySecret: roleCredentials.AccessKeySecret,
+		SecurityToken:   roleCredentials.SecurityToken,
+		ProviderName:    p.GetProviderName(),
+	}
+	p.expiration = expiration
+
+	return nil
+}
+
+// metadataToken requests an IMDSv2 session token
+func (p *ECSMetadataProvider) metadataToken() (string, error) {
+	req, err := http.NewRequest(http.MethodPut, p.endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aliyun-ecs-metadata-token-ttl-seconds", metadataTokenTTL)
+
+	return p.do(req)
+}
+
+// get performs a GET request against the metadata service
+func (p *ECSMetadataProvider) get(path, token string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, p.endpoint+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-aliyun-ecs-metadata-token", token)
+	}
+
+	return p.do(req)
+}
+
+// do executes a metadata request and returns the response body
+func (p *ECSMetadataProvider) do(req *http.Request) (string, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata service returned status %d", resp.StatusCode)
+	}
+
+	return string(body), nil
+}
@@ -0,0 +1,317 @@
+package client_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/config"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
+)
+
+// metadataService is a stand-in for the ECS metadata service serving the credentials of a RAM role
+type metadataService struct {
+	token      string // IMDSv2 token, empty when tokens are not supported
+	role       string
+	code       string
+	expiresIn  time.Duration
+	mu         sync.Mutex
+	issued     int // Credentials returned so far, numbering the access keys
+	tokenFails int // Requests without the IMDSv2 token
+}
+
+// newMetadataServer starts a metadata service stand-in
+func newMetadataServer(t *testing.T, m *metadataService) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(m)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// ServeHTTP implements http.Handler
+func (m *metadataService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	const credentialsPath = "/latest/meta-data/ram/security-credentials/"
+
+	if r.URL.Path == "/latest/api/token" {
+		if m.token == "" || r.Method != http.MethodPut || r.Header.Get("X-aliyun-ecs-metadata-token-ttl-seconds") == "" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, m.token)
+		return
+	}
+
+	if m.token != "" && r.Header.Get("X-aliyun-ecs-metadata-token") != m.token {
+		m.tokenFails++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case credentialsPath:
+		fmt.Fprint(w, m.role)
+	case credentialsPath + m.role:
+		m.issued++
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"Code":            m.code,
+			"AccessKeyId":     fmt.Sprintf("key-%d", m.issued),
+			"AccessKeySecret": "secret",
+			"SecurityToken":   "token",
+			"Expiration":      time.Now().Add(m.expiresIn).UTC().Format("2006-01-02T15:04:05Z"),
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestECSMetadataProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		roleName   string
+		code       string
+		expiresIn  time.Duration
+		wantKeys   []string
+		wantIssued int
+		wantErr    string
+	}{
+		{
+			name:       "discovered role with IMDSv2 token",
+			token:      "imds-token",
+			code:       "Success",
+			expiresIn:  time.Hour,
+			wantKeys:   []string{"key-1", "key-1"},
+			wantIssued: 1,
+		},
+		{
+			name:       "configured role without IMDSv2",
+			roleName:   "exporter",
+			code:       "Success",
+			expiresIn:  time.Hour,
+			wantKeys:   []string{"key-1", "key-1"},
+			wantIssued: 1,
+		},
+		{
+			name:       "refreshed within the refresh window",
+			code:       "Success",
+			expiresIn:  time.Minute,
+			wantKeys:   []string{"key-1", "key-2"},
+			wantIssued: 2,
+		},
+		{
+			name:      "unsuccessful code",
+			code:      "Failed",
+			expiresIn: time.Hour,
+			wantErr:   `code "Failed"`,
+		},
+		{
+			name:     "unknown role",
+			roleName: "missing",
+			code:     "Success",
+			wantErr:  "status 404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := &metadataService{token: tt.token, role: "exporter", code: tt.code, expiresIn: tt.expiresIn}
+			server := newMetadataServer(t, metadata)
+			provider := client.NewECSMetadataProvider(server.URL, tt.roleName)
+
+			if tt.wantErr != "" {
+				_, err := provider.GetCredentials()
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetCredentials() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			for _, wantKey := range tt.wantKeys {
+				creds, err := provider.GetCredentials()
+				if err != nil {
+					t.Fatalf("GetCredentials() error = %v", err)
+				}
+				if creds.AccessKeyId != wantKey || creds.SecurityToken != "token" || creds.ProviderName != "ecs_ram_role" {
+					t.Errorf("GetCredentials() = %+v, want access key %s", creds, wantKey)
+				}
+			}
+			if metadata.issued != tt.wantIssued {
+				t.Errorf("metadata service issued %d credentials, want %d", metadata.issued, tt.wantIssued)
+			}
+			if metadata.tokenFails != 0 {
+				t.Errorf("got %d requests without the IMDSv2 token", metadata.tokenFails)
+			}
+		})
+	}
+}
+
+// writeProfiles writes an Alibaba Cloud credentials file
+func writeProfiles(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewCredentialsProvider(t *testing.T) {
+	t.Setenv("ALIBABA_CLOUD_CREDENTIALS_FILE", "")
+	t.Setenv("ALIBABA_CLOUD_PROFILE", "")
+
+	metadata := newMetadataServer(t, &metadataService{role: "exporter", code: "Success", expiresIn: time.Hour})
+	profileMetadata := newMetadataServer(t, &metadataService{role: "exporter", code: "Success", expiresIn: time.Hour})
+	profiles := writeProfiles(t, `
+[default]
+type = access_key
+access_key_id = profile-key
+access_key_secret = profile-secret
+
+[instance]
+type = ecs_ram_role
+role_name = exporter
+`)
+
+	tests := []struct {
+		name      string
+		cfg       config.AlicloudConfig
+		wantKey   string
+		wantToken string
+		wantErr   bool
+	}{
+		{
+			name:    "access key",
+			cfg:     config.AlicloudConfig{AccessKeyID: "ak", AccessKeySecret: "sk"},
+			wantKey: "ak",
+		},
+		{
+			name: "sts token",
+			cfg: config.AlicloudConfig{AccessKeyID: "ak", AccessKeySecret: "sk", Credentials: config.CredentialsConfig{
+				Type:          "sts_token",
+				SecurityToken: "sts",
+			}},
+			wantKey:   "ak",
+			wantToken: "sts",
+		},
+		{
+			name: "ecs ram role",
+			cfg: config.AlicloudConfig{Credentials: config.CredentialsConfig{
+				Type:             "ecs_ram_role",
+				MetadataEndpoint: metadata.URL,
+			}},
+			wantKey:   "key-1",
+			wantToken: "token",
+		},
+		{
+			name: "profile",
+			cfg: config.AlicloudConfig{Credentials: config.CredentialsConfig{
+				Type: "profile",
+				File: profiles,
+			}},
+			wantKey: "profile-key",
+		},
+		{
+			name: "ecs ram role profile",
+			cfg: config.AlicloudConfig{Credentials: config.CredentialsConfig{
+				Type:             "profile",
+				Profile:          "instance",
+				File:             profiles,
+				MetadataEndpoint: profileMetadata.URL,
+			}},
+			wantKey:   "key-1",
+			wantToken: "token",
+		},
+		{
+			name: "missing profile",
+			cfg: config.AlicloudConfig{Credentials: config.CredentialsConfig{
+				Type:    "profile",
+				Profile: "missing",
+				File:    profiles,
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "replay",
+			cfg:     config.AlicloudConfig{ReplayDir: t.TempDir(), Credentials: config.CredentialsConfig{Type: "ecs_ram_role"}},
+			wantKey: "replay",
+		},
+		{
+			name:    "unsupported type",
+			cfg:     config.AlicloudConfig{Credentials: config.CredentialsConfig{Type: "password"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := client.NewCredentialsProvider(&tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewCredentialsProvider() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewCredentialsProvider() error = %v", err)
+			}
+
+			creds, err := provider.GetCredentials()
+			if err != nil {
+				t.Fatalf("GetCredentials() error = %v", err)
+			}
+			if creds.AccessKeyId != tt.wantKey || creds.SecurityToken != tt.wantToken {
+				t.Errorf("GetCredentials() = %+v, want access key %q and security token %q", creds, tt.wantKey, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestProfileCredentialsFilePerAccount(t *testing.T) {
+	t.Setenv("ALIBABA_CLOUD_CREDENTIALS_FILE", "")
+	t.Setenv("ALIBABA_CLOUD_PROFILE", "")
+
+	accounts := []struct {
+		file    string
+		wantKey string
+	}{
+		{file: writeProfiles(t, "[default]\ntype = access_key\naccess_key_id = first\naccess_key_secret = s1\n"), wantKey: "first"},
+		{file: writeProfiles(t, "[default]\ntype = access_key\naccess_key_id = second\naccess_key_secret = s2\n"), wantKey: "second"},
+	}
+
+	var providers []credentials.CredentialsProvider
+	for _, account := range accounts {
+		provider, err := client.NewCredentialsProvider(&config.AlicloudConfig{Credentials: config.CredentialsConfig{
+			Type: "profile",
+			File: account.file,
+		}})
+		if err != nil {
+			t.Fatalf("NewCredentialsProvider() error = %v", err)
+		}
+		providers = append(providers, provider)
+	}
+
+	// Loading an account doesn't change the credentials file of the accounts loaded before
+	for i, provider := range providers {
+		creds, err := provider.GetCredentials()
+		if err != nil {
+			t.Fatalf("GetCredentials() error = %v", err)
+		}
+		if creds.AccessKeyId != accounts[i].wantKey {
+			t.Errorf("account %d uses access key %s, want %s", i, creds.AccessKeyId, accounts[i].wantKey)
+		}
+	}
+	if file := os.Getenv("ALIBABA_CLOUD_CREDENTIALS_FILE"); file != "" {
+		t.Errorf("ALIBABA_CLOUD_CREDENTIALS_FILE = %q, want it left unset", file)
+	}
+}
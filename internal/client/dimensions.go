@@ -0,0 +1,38 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// maxDimensionsPerRequest is the maximum number of dimension objects CMS accepts in a single
+// DescribeMetricLast request
+const maxDimensionsPerRequest = 50
+
+// Dimension selects the series of a metric by CMS dimension values, such as
+// {"instanceId": "lb-xxx", "port": "80", "protocol": "tcp"}. Keys are case-sensitive.
+type Dimension map[string]string
+
+// encodeDimensions renders dimensions as the JSON array DescribeMetricLast expects, split into
+// chunks of at most size objects. No dimensions yield a single empty chunk querying all series.
+func encodeDimensions(dimensions []Dimension, size int) ([]string, error) {
+	if len(dimensions) == 0 {
+		return []string{""}, nil
+	}
+
+	chunks := make([]string, 0, (len(dimensions)+size-1)/size)
+	for start := 0; start < len(dimensions); start += size {
+		end := start + size
+		if end > len(dimensions) {
+			end = len(dimensions)
+		}
+
+		data, err := json.Marshal(dimensions[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode dimensions: %w", err)
+		}
+		chunks = append(chunks, string(data))
+	}
+
+	return chunks, nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDimensions(t *testing.T) {
+	instances := func(from, to int) []Dimension {
+		dimensions := make([]Dimension, 0, to-from)
+		for i := from; i < to; i++ {
+			dimensions = append(dimensions, Dimension{"instanceId": fmt.Sprintf("i-%d", i)})
+		}
+		return dimensions
+	}
+	encoded := func(from, to int) string {
+		objects := make([]string, 0, to-from)
+		for i := from; i < to; i++ {
+			objects = append(objects, fmt.Sprintf(`{"instanceId":"i-%d"}`, i))
+		}
+		return "[" + strings.Join(objects, ",") + "]"
+	}
+
+	tests := []struct {
+		name       string
+		dimensions []Dimension
+		size       int
+		want       []string
+	}{
+		{
+			name: "no dimensions query all series",
+			size: maxDimensionsPerRequest,
+			want: []string{""},
+		},
+		{
+			name:       "single filter with several keys",
+			dimensions: []Dimension{{"instanceId": "lb-1", "port": "80", "protocol": "tcp"}},
+			size:       maxDimensionsPerRequest,
+			want:       []string{`[{"instanceId":"lb-1","port":"80","protocol":"tcp"}]`},
+		},
+		{
+			name:       "keys keep their case",
+			dimensions: []Dimension{{"instanceId": "i-1"}, {"InstanceId": "i-2"}},
+			size:       maxDimensionsPerRequest,
+			want:       []string{`[{"instanceId":"i-1"},{"InstanceId":"i-2"}]`},
+		},
+		{
+			name:       "exactly one chunk",
+			dimensions: instances(0, maxDimensionsPerRequest),
+			size:       maxDimensionsPerRequest,
+			want:       []string{encoded(0, maxDimensionsPerRequest)},
+		},
+		{
+			name:       "split at the request limit",
+			dimensions: instances(0, 2*maxDimensionsPerRequest+1),
+			size:       maxDimensionsPerRequest,
+			want: []string{
+				encoded(0, maxDimensionsPerRequest),
+				encoded(maxDimensionsPerRequest, 2*maxDimensionsPerRequest),
+				`[{"instanceId":"i-100"}]`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeDimensions(tt.dimensions, tt.size)
+			if err != nil {
+				t.Fatalf("encodeDimensions() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeDimensions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
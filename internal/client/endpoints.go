@@ -0,0 +1,42 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
+)
+
+// endpoint is an API endpoint used instead of the one resolved by the SDK
+type endpoint struct {
+	scheme string
+	host   string
+}
+
+// parseEndpoints parses the endpoint overrides of alicloud.endpoints, keyed by product
+func parseEndpoints(overrides map[string]string) (map[string]endpoint, error) {
+	endpoints := make(map[string]endpoint, len(overrides))
+	for product, raw := range overrides {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s endpoint %q, must be an http or https URL", product, raw)
+		}
+		endpoints[product] = endpoint{scheme: u.Scheme, host: u.Host}
+	}
+	return endpoints, nil
+}
+
+// applyEndpoint points an SDK client at the endpoint override of a product, if any
+func applyEndpoint(sdkClient *sdk.Client, endpoints map[string]endpoint, product string) {
+	if ep, found := endpoints[product]; found {
+		sdkClient.Domain = ep.host
+	}
+}
+
+// scheme returns the scheme of requests to a product, https unless its endpoint is overridden
+func (c *Client) scheme(product string) string {
+	if ep, found := c.endpoints[product]; found {
+		return ep.scheme
+	}
+	return "https"
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// flightGroup coalesces concurrent identical requests, such as CMS requests or inventory
+// listings, into a single API call
+type flightGroup[T any] struct {
+	flights map[string]*flight[T]
+	mu      sync.Mutex
+}
+
+// flight is a call in progress, its result is set before done is closed
+type flight[T any] struct {
+	done     chan struct{}
+	response T
+	err      error
+}
+
+// newFlightGroup creates an empty flight group
+func newFlightGroup[T any]() *flightGroup[T] {
+	return &flightGroup[T]{flights: make(map[string]*flight[T])}
+}
+
+// do runs call unless a call with the same key is in progress, in which case it waits for that
+// call and returns its result with shared set. Waiting stops when ctx ends, and the call is run
+// again when the caller that started it gave up before it completed.
+func (g *flightGroup[T]) do(ctx context.Context, key string, call func() (T, error)) (response T, shared bool, err error) {
+	for {
+		g.mu.Lock()
+		if f, found := g.flights[key]; found {
+			g.mu.Unlock()
+
+			select {
+			case <-f.done:
+			case <-ctx.Done():
+				return response, true, ctx.Err()
+			}
+
+			// The context of the caller running the call ended, this caller may still succeed
+			if isContextError(f.err) && ctx.Err() == nil {
+				continue
+			}
+			return f.response, true, f.err
+		}
+
+		f := &flight[T]{done: make(chan struct{})}
+		g.flights[key] = f
+		g.mu.Unlock()
+
+		g.run(key, f, call)
+		return f.response, false, f.err
+	}
+}
+
+// run executes the call of a flight and releases its waiters, even if the call panics
+func (g *flightGroup[T]) run(key string, f *flight[T], call func() (T, error)) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+
+	// Waiters see errFlightAborted if call panics
+	f.err = errFlightAborted
+	f.response, f.err = call()
+}
+
+// errFlightAborted is the result of waiters of a call that panicked
+var errFlightAborted = errors.New("concurrent identical request failed")
+
+// isContextError reports whether err was caused by a canceled or expired context
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
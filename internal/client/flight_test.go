@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/cms"
+)
+
+func TestFlightGroupCoalescesCalls(t *testing.T) {
+	g := newFlightGroup[*cms.DescribeMetricLastResponse]()
+	release := make(chan struct{})
+	var calls, shared int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, isShared, err := g.do(context.Background(), "key", func() (*cms.DescribeMetricLastResponse, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return &cms.DescribeMetricLastResponse{Datapoints: "[]"}, nil
+			})
+			if err != nil || response.Datapoints != "[]" {
+				t.Errorf("do() = %v, %v", response, err)
+			}
+			if isShared {
+				atomic.AddInt32(&shared, 1)
+			}
+		}()
+	}
+
+	// Let every caller join the call in progress before it completes
+	waitFor(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.flights["key"] != nil
+	})
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 || shared != 9 {
+		t.Errorf("got %d calls and %d shared results, want 1 and 9", calls, shared)
+	}
+	if len(g.flights) != 0 {
+		t.Errorf("%d flights left after completion", len(g.flights))
+	}
+}
+
+func TestFlightGroupRetriesAbandonedCall(t *testing.T) {
+	g := newFlightGroup[*cms.DescribeMetricLastResponse]()
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+
+	go func() {
+		_, _, _ = g.do(ctx, "key", func() (*cms.DescribeMetricLastResponse, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	}()
+	<-started
+
+	done := make(chan error)
+	var calls int32
+	go func() {
+		_, _, err := g.do(context.Background(), "key", func() (*cms.DescribeMetricLastResponse, error) {
+			atomic.AddInt32(&calls, 1)
+			return &cms.DescribeMetricLastResponse{}, nil
+		})
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("do() error = %v, want the call to be run again", err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+}
+
+// waitFor waits up to a second for condition to hold
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,461 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/r_kvstore"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/rds"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
+)
+
+const (
+	// slbPageSize is the maximum page size of DescribeLoadBalancers
+	slbPageSize = 100
+
+	// rdsPageSize is the maximum page size of DescribeDBInstances
+	rdsPageSize = 100
+
+	// redisPageSize is the maximum page size of DescribeInstances
+	redisPageSize = 50
+)
+
+// Instance holds the inventory metadata of a cloud resource
+type Instance struct {
+	ID            string
+	Region        string
+	Name          string
+	Spec          string
+	Engine        string
+	EngineVersion string
+	NetworkType   string
+	VpcID         string
+	Zone          string
+	ChargeType    string
+	Status        string
+	CreateTime    string
+	ExpireTime    string
+	Tags          map[string]string
+}
+
+// InventoryCache caches the instances of a service per region
+type InventoryCache struct {
+	entries  map[string]inventoryEntry
+	services map[string]bool // Services with an inventory, refreshed in the background
+	ttl      time.Duration
+	mu       sync.RWMutex
+}
+
+type inventoryEntry struct {
+	instances  []Instance
+	expiration time.Time
+}
+
+// NewInventoryCache creates a new inventory cache
+func NewInventoryCache(ttl time.Duration) *InventoryCache {
+	return &InventoryCache{
+		entries:  make(map[string]inventoryEntry),
+		services: make(map[string]bool),
+		ttl:      ttl,
+	}
+}
+
+// Get retrieves the cached instances of a key
+func (ic *InventoryCache) Get(key string) ([]Instance, bool) {
+	ic.mu.RLock()
+	defer ic.mu.RUnlock()
+
+	entry, exists := ic.entries[key]
+	if !exists || time.Now().After(entry.expiration) {
+		return nil, false
+	}
+	return entry.instances, true
+}
+
+// Set stores the instances of a key, keys are formatted as service:region
+func (ic *InventoryCache) Set(key string, instances []Instance) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	ic.entries[key] = inventoryEntry{
+		instances:  instances,
+		expiration: time.Now().Add(ic.ttl),
+	}
+	if service, _, found := strings.Cut(key, ":"); found {
+		ic.services[service] = true
+	}
+}
+
+// Services returns the services with a cached inventory, sorted by name
+func (ic *InventoryCache) Services() []string {
+	ic.mu.RLock()
+	defer ic.mu.RUnlock()
+
+	services := make([]string, 0, len(ic.services))
+	for service := range ic.services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// inventoryInstances returns every instance of a service in the cached inventory by ID
+func (c *Client) inventoryInstances(service string) map[string]Instance {
+	listed := make(map[string]Instance)
+	for _, region := range c.GetRegions() {
+		instances, _ := c.inventory.Get(service + ":" + region)
+		for _, instance := range instances {
+			listed[instance.ID] = instance
+		}
+	}
+	return listed
+}
+
+// DescribeSLBInstances lists the load balancers of every configured region
+func (c *Client) DescribeSLBInstances(ctx context.Context) ([]Instance, error) {
+	return c.describeInstances(ctx, "slb", true)
+}
+
+// DescribeRDSInstances lists the RDS instances of every configured region
+func (c *Client) DescribeRDSInstances(ctx context.Context) ([]Instance, error) {
+	return c.describeInstances(ctx, "rds", true)
+}
+
+// DescribeRedisInstances lists the Redis (KVStore) instances of every configured region
+func (c *Client) DescribeRedisInstances(ctx context.Context) ([]Instance, error) {
+	return c.describeInstances(ctx, "redis", true)
+}
+
+// regionDescriber returns the function listing the instances of a service in a region
+func (c *Client) regionDescriber(service string) (func(ctx context.Context, region string) ([]Instance, error), error) {
+	switch service {
+	case "slb":
+		return func(ctx context.Context, region string) ([]Instance, error) {
+			slbClient, exists := c.slbClients[region]
+			if !exists {
+				return nil, fmt.Errorf("no SLB client configured for region %s", region)
+			}
+			return c.describeLoadBalancers(ctx, slbClient, region)
+		}, nil
+	case "rds":
+		return func(ctx context.Context, region string) ([]Instance, error) {
+			rdsClient, exists := c.rdsClients[region]
+			if !exists {
+				return nil, fmt.Errorf("no RDS client configured for region %s", region)
+			}
+			return c.describeDBInstances(ctx, rdsClient, region)
+		}, nil
+	case "redis":
+		return func(ctx context.Context, region string) ([]Instance, error) {
+			kvClient, exists := c.kvClients[region]
+			if !exists {
+				return nil, fmt.Errorf("no Redis client configured for region %s", region)
+			}
+			return c.describeKVStoreInstances(ctx, kvClient, region)
+		}, nil
+	default:
+		return nil, fmt.Errorf("no inventory for service %s", service)
+	}
+}
+
+// describeInstances lists the instances of a service in every configured region, served from the
+// inventory cache when useCache is set. Regions are listed in parallel, requests going through
+// the rate limiter, and regions that fail are skipped and reported in the returned error.
+func (c *Client) describeInstances(ctx context.Context, service string, useCache bool) ([]Instance, error) {
+	describe, err := c.regionDescriber(service)
+	if err != nil {
+		return nil, err
+	}
+
+	regions := c.GetRegions()
+	results := make([][]Instance, len(regions))
+	errs := make([]error, len(regions))
+
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		key := service + ":" + region
+		if useCache {
+			if cached, found := c.inventory.Get(key); found {
+				results[i] = cached
+				continue
+			}
+		}
+
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+
+			// Concurrent listings of a region, such as by scrapes missing the cache, share a call
+			instances, _, err := c.listings.do(ctx, key, func() ([]Instance, error) {
+				return c.listRegionInstances(ctx, service, region, describe)
+			})
+			results[i] = instances
+			if err != nil {
+				errs[i] = fmt.Errorf("region %s: %w", region, err)
+			}
+		}(i, region)
+	}
+	wg.Wait()
+
+	var instances []Instance
+	for _, regionInstances := range results {
+		instances = append(instances, regionInstances...)
+	}
+	return instances, errors.Join(errs...)
+}
+
+// listRegionInstances lists the instances of a service in a region with describe and caches them
+func (c *Client) listRegionInstances(ctx context.Context, service, region string, describe func(ctx context.Context, region string) ([]Instance, error)) ([]Instance, error) {
+	start := time.Now()
+	instances, err := describe(ctx, region)
+	if err != nil {
+		// Partial results are still returned, but only cached when the listing is complete
+		var partial *partialTagsError
+		if !errors.As(err, &partial) {
+			return instances, err
+		}
+	}
+
+	c.inventory.Set(service+":"+region, instances)
+	c.getMetrics().recordInventorySize(service, region, len(instances))
+	c.getLogger().WithFields(map[string]interface{}{
+		"service":   service,
+		"region":    region,
+		"instances": len(instances),
+		"duration":  time.Since(start).String(),
+	}).Debug("Listed instances")
+	return instances, err
+}
+
+// refreshInventory lists again the instances of the services listed before, every interval until
+// the client is closed, so that lookups are served from an up to date inventory
+func (c *Client) refreshInventory(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.background.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, service := range c.inventory.Services() {
+			ctx, cancel := context.WithTimeout(c.background, interval)
+			_, err := c.describeInstances(ctx, service, false)
+			cancel()
+			if err != nil && c.background.Err() == nil {
+				c.getLogger().WithService(service).WithError(err).Warn("Failed to refresh instance inventory")
+			}
+		}
+	}
+}
+
+// describeLoadBalancers lists all load balancers of a region page by page
+func (c *Client) describeLoadBalancers(ctx context.Context, slbClient *slb.Client, region string) ([]Instance, error) {
+	request := slb.CreateDescribeLoadBalancersRequest()
+	request.Scheme = c.scheme("slb")
+	request.PageSize = requests.NewInteger(slbPageSize)
+
+	var instances []Instance
+	for page := 1; ; page++ {
+		request.PageNumber = requests.NewInteger(page)
+
+		var response *slb.DescribeLoadBalancersResponse
+		err := c.withRetry(ctx, "DescribeLoadBalancers", func() error {
+			var callErr error
+			response, callErr = slbClient.DescribeLoadBalancers(request)
+			return callErr
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, lb := range response.LoadBalancers.LoadBalancer {
+			tags := make(map[string]string)
+			for _, tag := range lb.Tags.Tag {
+				tags[tag.TagKey] = tag.TagValue
+			}
+
+			instances = append(instances, Instance{
+				ID:          lb.LoadBalancerId,
+				Region:      region,
+				Name:        lb.LoadBalancerName,
+				Spec:        lb.LoadBalancerSpec,
+				NetworkType: lb.NetworkType,
+				VpcID:       lb.VpcId,
+				Zone:        lb.MasterZoneId,
+				ChargeType:  lb.PayType,
+				Status:      lb.LoadBalancerStatus,
+				CreateTime:  lb.CreateTime,
+				Tags:        tags,
+			})
+		}
+
+		if len(response.LoadBalancers.LoadBalancer) < slbPageSize || len(instances) >= response.TotalCount {
+			return instances, nil
+		}
+	}
+}
+
+// describeDBInstances lists all RDS instances of a region page by page, tags are resolved
+// through ListTagResources since DescribeDBInstances doesn't return them
+func (c *Client) describeDBInstances(ctx context.Context, rdsClient *rds.Client, region string) ([]Instance, error) {
+	request := rds.CreateDescribeDBInstancesRequest()
+	request.Scheme = c.scheme("rds")
+	request.PageSize = requests.NewInteger(rdsPageSize)
+
+	var instances []Instance
+	for page := 1; ; page++ {
+		request.PageNumber = requests.NewInteger(page)
+
+		var response *rds.DescribeDBInstancesResponse
+		err := c.withRetry(ctx, "DescribeDBInstances", func() error {
+			var callErr error
+			response, callErr = rdsClient.DescribeDBInstances(request)
+			return callErr
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, db := range response.Items.DBInstance {
+			instances = append(instances, Instance{
+				ID:            db.DBInstanceId,
+				Region:        region,
+				Name:          db.DBInstanceDescription,
+				Spec:          db.DBInstanceClass,
+				Engine:        db.Engine,
+				EngineVersion: db.EngineVersion,
+				NetworkType:   db.InstanceNetworkType,
+				VpcID:         db.VpcId,
+				Zone:          db.ZoneId,
+				ChargeType:    db.PayType,
+				Status:        db.DBInstanceStatus,
+				CreateTime:    db.CreateTime,
+				ExpireTime:    db.ExpireTime,
+			})
+		}
+
+		if len(response.Items.DBInstance) < rdsPageSize || len(instances) >= response.TotalRecordCount {
+			break
+		}
+	}
+
+	// Tags are looked up in the listed region only, ListTagResources omits untagged instances
+	ids := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, instance.ID)
+	}
+	tags := make(map[string]map[string]string, len(ids))
+	resolved := make(map[string]bool, len(ids))
+	var errs []error
+	for start := 0; start < len(ids); start += rdsTagBatchSize {
+		end := start + rdsTagBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		found, err := c.listRDSTags(ctx, rdsClient, ids[start:end])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for id, instanceTags := range found {
+			tags[id] = instanceTags
+		}
+		for _, id := range ids[start:end] {
+			resolved[id] = true
+		}
+	}
+
+	for i := range instances {
+		id := instances[i].ID
+		if !resolved[id] {
+			continue
+		}
+		instanceTags, found := tags[id]
+		if !found {
+			instanceTags = make(map[string]string)
+		}
+		instances[i].Tags = instanceTags
+	}
+
+	// The listing is complete even when tags could only be partially resolved
+	if len(errs) > 0 {
+		return instances, &partialTagsError{err: errors.Join(errs...)}
+	}
+	return instances, nil
+}
+
+// partialTagsError reports instances listed completely but with only part of their tags resolved,
+// so that the listing can still be cached
+type partialTagsError struct {
+	err error
+}
+
+// Error implements error
+func (e *partialTagsError) Error() string {
+	return "failed to resolve tags: " + e.err.Error()
+}
+
+// Unwrap returns the tag lookup errors
+func (e *partialTagsError) Unwrap() error {
+	return e.err
+}
+
+// describeKVStoreInstances lists all Redis instances of a region page by page
+func (c *Client) describeKVStoreInstances(ctx context.Context, kvClient *r_kvstore.Client, region string) ([]Instance, error) {
+	request := r_kvstore.CreateDescribeInstancesRequest()
+	request.Scheme = c.scheme("redis")
+	request.PageSize = requests.NewInteger(redisPageSize)
+
+	var instances []Instance
+	for page := 1; ; page++ {
+		request.PageNumber = requests.NewInteger(page)
+
+		var response *r_kvstore.DescribeInstancesResponse
+		err := c.withRetry(ctx, "DescribeInstances", func() error {
+			var callErr error
+			response, callErr = kvClient.DescribeInstances(request)
+			return callErr
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, kv := range response.Instances.KVStoreInstance {
+			tags := make(map[string]string)
+			for _, tag := range kv.Tags.Tag {
+				tags[tag.Key] = tag.Value
+			}
+
+			instances = append(instances, Instance{
+				ID:            kv.InstanceId,
+				Region:        region,
+				Name:          kv.InstanceName,
+				Spec:          kv.InstanceClass,
+				Engine:        kv.InstanceType,
+				EngineVersion: kv.EngineVersion,
+				NetworkType:   kv.NetworkType,
+				VpcID:         kv.VpcId,
+				Zone:          kv.ZoneId,
+				ChargeType:    kv.ChargeType,
+				Status:        kv.InstanceStatus,
+				CreateTime:    kv.CreateTime,
+				ExpireTime:    kv.EndTime,
+				Tags:          tags,
+			})
+		}
+
+		if len(response.Instances.KVStoreInstance) < redisPageSize || len(instances) >= response.TotalCount {
+			return instances, nil
+		}
+	}
+}
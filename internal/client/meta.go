@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/cms"
+)
+
+// metricMetaPageSize is the page size of DescribeMetricMetaList requests
+const metricMetaPageSize = 100
+
+// MetricMeta describes a metric of a CMS namespace
+type MetricMeta struct {
+	Namespace   string
+	MetricName  string
+	Description string
+	Unit        string
+	Periods     []string
+	Dimensions  []string
+	Statistics  []string
+}
+
+// MetaCache caches the metric metadata of CMS namespaces
+type MetaCache struct {
+	entries map[string]metaEntry
+	ttl     time.Duration
+	mu      sync.RWMutex
+}
+
+type metaEntry struct {
+	metas      []MetricMeta
+	expiration time.Time
+}
+
+// NewMetaCache creates a new metric metadata cache
+func NewMetaCache(ttl time.Duration) *MetaCache {
+	return &MetaCache{
+		entries: make(map[string]metaEntry),
+		ttl:     ttl,
+	}
+}
+
+// Get retrieves the cached metadata of a namespace
+func (mc *MetaCache) Get(namespace string) ([]MetricMeta, bool) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	entry, exists := mc.entries[namespace]
+	if !exists || time.Now().After(entry.expiration) {
+		return nil, false
+	}
+	return entry.metas, true
+}
+
+// Set stores the metadata of a namespace
+func (mc *MetaCache) Set(namespace string, metas []MetricMeta) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.entries[namespace] = metaEntry{
+		metas:      metas,
+		expiration: time.Now().Add(mc.ttl),
+	}
+}
+
+// DescribeMetricMeta lists the metrics available in a CMS namespace with their units, periods,
+// dimensions and descriptions using the DescribeMetricMetaList API
+func (c *Client) DescribeMetricMeta(ctx context.Context, namespace string) ([]MetricMeta, error) {
+	if metas, found := c.metaCache.Get(namespace); found {
+		return metas, nil
+	}
+
+	request := cms.CreateDescribeMetricMetaListRequest()
+	request.Scheme = c.scheme("cms")
+	request.Namespace = namespace
+	request.PageSize = requests.NewInteger(metricMetaPageSize)
+
+	var metas []MetricMeta
+	for page := 1; ; page++ {
+		request.PageNumber = requests.NewInteger(page)
+
+		var response *cms.DescribeMetricMetaListResponse
+		err := c.withRetry(ctx, "DescribeMetricMetaList", func() error {
+			var callErr error
+			response, callErr = c.cmsClient.DescribeMetricMetaList(request)
+			return callErr
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe metrics of namespace %s: %w", namespace, err)
+		}
+
+		for _, resource := range response.Resources.Resource {
+			metas = append(metas, MetricMeta{
+				Namespace:   namespace,
+				MetricName:  resource.MetricName,
+				Description: resource.Description,
+				Unit:        resource.Unit,
+				Periods:     splitList(resource.Periods),
+				Dimensions:  splitList(resource.Dimensions),
+				Statistics:  splitList(resource.Statistics),
+			})
+		}
+
+		total, _ := strconv.Atoi(response.TotalCount)
+		if len(response.Resources.Resource) < metricMetaPageSize || len(metas) >= total {
+			break
+		}
+	}
+
+	c.metaCache.Set(namespace, metas)
+	return metas, nil
+}
+
+// splitList splits a comma-separated list returned by CMS
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	items := strings.Split(value, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus metrics describing Alicloud API usage by the client
+type Metrics struct {
+	pagesFetched *prometheus.CounterVec
+	regionErrors *prometheus.CounterVec
+	apiRequests  *prometheus.CounterVec
+	apiRetries   *prometheus.CounterVec
+	deduplicated *prometheus.CounterVec
+
+	tagCacheHits      *prometheus.CounterVec
+	tagCacheMisses    prometheus.Counter
+	tagCacheEvictions prometheus.Counter
+
+	inventorySize *prometheus.GaugeVec
+}
+
+// NewMetrics creates the client metrics using the given prefix and constant labels
+func NewMetrics(metricPrefix string, constLabels map[string]string) *Metrics {
+	return &Metrics{
+		pagesFetched: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "cms", "pages_fetched_total"),
+			Help:        "Total number of DescribeMetricLast result pages fetched from Alicloud CMS.",
+			ConstLabels: constLabels,
+		}, []string{"namespace", "metric"}),
+		regionErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "cms", "region_errors_total"),
+			Help:        "Total number of failed Alicloud CMS queries by region.",
+			ConstLabels: constLabels,
+		}, []string{"region", "namespace"}),
+		apiRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "api", "requests_total"),
+			Help:        "Total number of Alicloud API requests by API and result code.",
+			ConstLabels: constLabels,
+		}, []string{"api", "code"}),
+		apiRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "api", "retries_total"),
+			Help:        "Total number of retried Alicloud API requests by API and the code that caused the retry.",
+			ConstLabels: constLabels,
+		}, []string{"api", "code"}),
+		deduplicated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "cms", "deduplicated_requests_total"),
+			Help:        "Total number of DescribeMetricLast requests served by an identical concurrent request instead of a separate API call.",
+			ConstLabels: constLabels,
+		}, []string{"namespace", "metric"}),
+		tagCacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "tag_cache", "hits_total"),
+			Help:        "Total number of instance tag lookups served from the cache, by state (fresh or stale).",
+			ConstLabels: constLabels,
+		}, []string{"state"}),
+		tagCacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "tag_cache", "misses_total"),
+			Help:        "Total number of instance tag lookups not found in the cache or expired.",
+			ConstLabels: constLabels,
+		}),
+		tagCacheEvictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "tag_cache", "evictions_total"),
+			Help:        "Total number of least recently used instances evicted from the tag cache.",
+			ConstLabels: constLabels,
+		}),
+		inventorySize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "inventory", "instances"),
+			Help:        "Number of instances in the last successful inventory listing by service and region.",
+			ConstLabels: constLabels,
+		}, []string{"service", "region"}),
+	}
+}
+
+// Describe sends the client metric descriptors to the channel
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.pagesFetched.Describe(ch)
+	m.regionErrors.Describe(ch)
+	m.apiRequests.Describe(ch)
+	m.apiRetries.Describe(ch)
+	m.deduplicated.Describe(ch)
+	m.tagCacheHits.Describe(ch)
+	ch <- m.tagCacheMisses.Desc()
+	ch <- m.tagCacheEvictions.Desc()
+	m.inventorySize.Describe(ch)
+}
+
+// Collect sends the client metrics to the channel
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.pagesFetched.Collect(ch)
+	m.regionErrors.Collect(ch)
+	m.apiRequests.Collect(ch)
+	m.apiRetries.Collect(ch)
+	m.deduplicated.Collect(ch)
+	m.tagCacheHits.Collect(ch)
+	ch <- m.tagCacheMisses
+	ch <- m.tagCacheEvictions
+	m.inventorySize.Collect(ch)
+}
+
+// recordPage increments the pages fetched counter, it is a no-op when metrics are not attached
+func (m *Metrics) recordPage(namespace, metric string) {
+	if m == nil {
+		return
+	}
+	m.pagesFetched.WithLabelValues(namespace, metric).Inc()
+}
+
+// recordRegionError increments the region errors counter, it is a no-op when metrics are not attached
+func (m *Metrics) recordRegionError(region, namespace string) {
+	if m == nil {
+		return
+	}
+	m.regionErrors.WithLabelValues(region, namespace).Inc()
+}
+
+// recordRequest increments the API requests counter, it is a no-op when metrics are not attached
+func (m *Metrics) recordRequest(api, code string) {
+	if m == nil {
+		return
+	}
+	m.apiRequests.WithLabelValues(api, code).Inc()
+}
+
+// recordRetry increments the API retries counter, it is a no-op when metrics are not attached
+func (m *Metrics) recordRetry(api, code string) {
+	if m == nil {
+		return
+	}
+	m.apiRetries.WithLabelValues(api, code).Inc()
+}
+
+// recordDeduplicated increments the deduplicated requests counter, it is a no-op when metrics are not attached
+func (m *Metrics) recordDeduplicated(namespace, metric string) {
+	if m == nil {
+		return
+	}
+	m.deduplicated.WithLabelValues(namespace, metric).Inc()
+}
+
+// recordTagCacheHit increments the tag cache hits counter, it is a no-op when metrics are not attached
+func (m *Metrics) recordTagCacheHit(state string) {
+	if m == nil {
+		return
+	}
+	m.tagCacheHits.WithLabelValues(state).Inc()
+}
+
+// recordTagCacheMiss increments the tag cache misses counter, it is a no-op when metrics are not attached
+func (m *Metrics) recordTagCacheMiss() {
+	if m == nil {
+		return
+	}
+	m.tagCacheMisses.Inc()
+}
+
+// recordTagCacheEviction increments the tag cache evictions counter, it is a no-op when metrics are not attached
+func (m *Metrics) recordTagCacheEviction() {
+	if m == nil {
+		return
+	}
+	m.tagCacheEvictions.Inc()
+}
+
+// recordInventorySize sets the inventory size gauge of a region, it is a no-op when metrics are not attached
+func (m *Metrics) recordInventorySize(service, region string, instances int) {
+	if m == nil {
+		return
+	}
+	m.inventorySize.WithLabelValues(service, region).Set(float64(instances))
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"alicloud-exporter/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+)
+
+// RetryPolicy controls how failed Alicloud API calls are retried
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Jitter      float64
+}
+
+// NewRetryPolicy creates a retry policy from configuration, filling in defaults for unset values
+func NewRetryPolicy(cfg config.RetryConfig) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseBackoff: cfg.BaseBackoff,
+		MaxBackoff:  cfg.MaxBackoff,
+		Jitter:      cfg.Jitter,
+	}
+
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.BaseBackoff <= 0 {
+		policy.BaseBackoff = 200 * time.Millisecond
+	}
+	if policy.MaxBackoff < policy.BaseBackoff {
+		policy.MaxBackoff = policy.BaseBackoff
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		policy.Jitter = 0
+	}
+
+	return policy
+}
+
+// Backoff returns the delay before the given retry (1-based), with exponential growth and jitter
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	backoff := p.BaseBackoff
+	for i := 1; i < retry && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		backoff -= time.Duration(rand.Float64() * p.Jitter * float64(backoff))
+	}
+	return backoff
+}
+
+// retryableCodePrefixes lists Alicloud error codes that indicate a transient failure
+var retryableCodePrefixes = []string{
+	"Throttling",
+	"ServiceUnavailable",
+	"InternalError",
+	"SDK.TimeoutError",
+	"SDK.ServerUnreachable",
+}
+
+// ErrorCode returns the Alicloud error code of an error, or a generic code for other errors
+func ErrorCode(err error) string {
+	if err == nil {
+		return "Success"
+	}
+
+	var serverErr *sdkerrors.ServerError
+	if errors.As(err, &serverErr) {
+		if serverErr.ErrorCode() != "" {
+			return serverErr.ErrorCode()
+		}
+		return strconv.Itoa(serverErr.HttpStatus())
+	}
+
+	var clientErr *sdkerrors.ClientError
+	if errors.As(err, &clientErr) {
+		return clientErr.ErrorCode()
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "ContextDeadlineExceeded"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "ContextCanceled"
+	}
+
+	return "Unknown"
+}
+
+// IsRetryable reports whether an error is transient and the call may succeed if retried
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var serverErr *sdkerrors.ServerError
+	if errors.As(err, &serverErr) {
+		if serverErr.HttpStatus() >= 500 || serverErr.HttpStatus() == 429 {
+			return true
+		}
+		return hasRetryableCode(serverErr.ErrorCode())
+	}
+
+	var clientErr *sdkerrors.ClientError
+	if errors.As(err, &clientErr) {
+		if hasRetryableCode(clientErr.ErrorCode()) {
+			return true
+		}
+		// Client errors wrap network failures from the HTTP transport
+		return isNetworkError(clientErr.OriginError())
+	}
+
+	return isNetworkError(err)
+}
+
+// hasRetryableCode checks an error code against the retryable code prefixes
+func hasRetryableCode(code string) bool {
+	for _, prefix := range retryableCodePrefixes {
+		if strings.HasPrefix(code, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNetworkError reports whether err is a network timeout or connection failure
+func isNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// withRetry runs call under the rate limiter, retrying retryable failures according to the retry policy.
+// Each attempt is recorded in the API metrics, and retries never outlive the context deadline.
+func (c *Client) withRetry(ctx context.Context, api string, call func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		// Wait for rate limiter before every attempt
+		if waitErr := c.rateLimiter.Wait(ctx); waitErr != nil {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("rate limiter wait failed: %w", waitErr)
+		}
+
+		err = call()
+		code := ErrorCode(err)
+		c.getMetrics().recordRequest(api, code)
+
+		if err == nil || attempt >= c.retryPolicy.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		backoff := c.retryPolicy.Backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return err
+		}
+
+		c.getMetrics().recordRetry(api, code)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
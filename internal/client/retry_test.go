@@ -0,0 +1,183 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/client/clienttest"
+	"alicloud-exporter/internal/config"
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestIsRetryableAndErrorCode(t *testing.T) {
+	connRefused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name          string
+		err           error
+		wantRetryable bool
+		wantCode      string
+	}{
+		{name: "success", err: nil, wantRetryable: false, wantCode: "Success"},
+		{name: "throttling", err: sdkerrors.NewServerError(400, `{"Code":"Throttling.User"}`, ""), wantRetryable: true, wantCode: "Throttling.User"},
+		{name: "service unavailable", err: sdkerrors.NewServerError(503, `{"Code":"ServiceUnavailable"}`, ""), wantRetryable: true, wantCode: "ServiceUnavailable"},
+		{name: "5xx without code", err: sdkerrors.NewServerError(502, "Bad Gateway", ""), wantRetryable: true, wantCode: "502"},
+		{name: "429 with unknown code", err: sdkerrors.NewServerError(429, `{"Code":"TooManyRequests"}`, ""), wantRetryable: true, wantCode: "TooManyRequests"},
+		{name: "invalid parameter", err: sdkerrors.NewServerError(400, `{"Code":"InvalidParameter"}`, ""), wantRetryable: false, wantCode: "InvalidParameter"},
+		{name: "forbidden", err: sdkerrors.NewServerError(403, `{"Code":"Forbidden.RAM"}`, ""), wantRetryable: false, wantCode: "Forbidden.RAM"},
+		{name: "sdk timeout", err: sdkerrors.NewClientError("SDK.TimeoutError", "timeout", nil), wantRetryable: true, wantCode: "SDK.TimeoutError"},
+		{name: "client error wrapping network error", err: sdkerrors.NewClientError("SDK.Unknown", "failed", connRefused), wantRetryable: true, wantCode: "SDK.Unknown"},
+		{name: "client error", err: sdkerrors.NewClientError("SDK.CanNotResolveEndpoint", "no endpoint", nil), wantRetryable: false, wantCode: "SDK.CanNotResolveEndpoint"},
+		{name: "wrapped network error", err: fmt.Errorf("request failed: %w", connRefused), wantRetryable: true, wantCode: "Unknown"},
+		{name: "wrapped server error", err: fmt.Errorf("page 2: %w", sdkerrors.NewServerError(500, `{"Code":"InternalError"}`, "")), wantRetryable: true, wantCode: "InternalError"},
+		{name: "deadline exceeded", err: fmt.Errorf("wait: %w", context.DeadlineExceeded), wantRetryable: false, wantCode: "ContextDeadlineExceeded"},
+		{name: "canceled", err: context.Canceled, wantRetryable: false, wantCode: "ContextCanceled"},
+		{name: "other error", err: errors.New("boom"), wantRetryable: false, wantCode: "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := client.IsRetryable(tt.err); got != tt.wantRetryable {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.wantRetryable)
+			}
+			if got := client.ErrorCode(tt.err); got != tt.wantCode {
+				t.Errorf("ErrorCode() = %q, want %q", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := client.NewRetryPolicy(config.RetryConfig{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, backoff := range want {
+		if got := policy.Backoff(i + 1); got != backoff {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, backoff)
+		}
+	}
+
+	// Jitter only shortens the backoff
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Backoff(2) with jitter = %v, want between 100ms and 200ms", got)
+		}
+	}
+
+	// Unset values are defaulted
+	defaults := client.NewRetryPolicy(config.RetryConfig{Jitter: 2})
+	if defaults.MaxAttempts != 1 || defaults.BaseBackoff != 200*time.Millisecond || defaults.MaxBackoff != defaults.BaseBackoff || defaults.Jitter != 0 {
+		t.Errorf("NewRetryPolicy() with defaults = %+v", defaults)
+	}
+}
+
+const (
+	apiRequestsHelp = "# HELP alicloud_api_requests_total Total number of Alicloud API requests by API and result code.\n# TYPE alicloud_api_requests_total counter\n"
+	apiRetriesHelp  = "# HELP alicloud_api_retries_total Total number of retried Alicloud API requests by API and the code that caused the retry.\n# TYPE alicloud_api_retries_total counter\n"
+)
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          *clienttest.APIError
+		wantErr      bool
+		wantRequests int
+		wantMetrics  string
+	}{
+		{
+			name:         "throttling retried until success",
+			err:          &clienttest.APIError{Status: 400, Code: "Throttling.User", Times: 2},
+			wantRequests: 3,
+			wantMetrics: apiRequestsHelp + `
+alicloud_api_requests_total{api="DescribeMetricMetaList",code="Success"} 1
+alicloud_api_requests_total{api="DescribeMetricMetaList",code="Throttling.User"} 2
+` + apiRetriesHelp + `
+alicloud_api_retries_total{api="DescribeMetricMetaList",code="Throttling.User"} 2
+`,
+		},
+		{
+			name:         "429 retried",
+			err:          &clienttest.APIError{Status: 429, Code: "TooManyRequests", Times: 1},
+			wantRequests: 2,
+			wantMetrics: apiRequestsHelp + `
+alicloud_api_requests_total{api="DescribeMetricMetaList",code="Success"} 1
+alicloud_api_requests_total{api="DescribeMetricMetaList",code="TooManyRequests"} 1
+` + apiRetriesHelp + `
+alicloud_api_retries_total{api="DescribeMetricMetaList",code="TooManyRequests"} 1
+`,
+		},
+		{
+			name:         "5xx retried until attempts run out",
+			err:          &clienttest.APIError{Status: 503, Code: "ServiceUnavailable"},
+			wantErr:      true,
+			wantRequests: 3,
+			wantMetrics: apiRequestsHelp + `
+alicloud_api_requests_total{api="DescribeMetricMetaList",code="ServiceUnavailable"} 3
+` + apiRetriesHelp + `
+alicloud_api_retries_total{api="DescribeMetricMetaList",code="ServiceUnavailable"} 2
+`,
+		},
+		{
+			name:         "permanent error not retried",
+			err:          &clienttest.APIError{Status: 400, Code: "InvalidParameter"},
+			wantErr:      true,
+			wantRequests: 1,
+			wantMetrics: apiRequestsHelp + `
+alicloud_api_requests_total{api="DescribeMetricMetaList",code="InvalidParameter"} 1
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clienttest.New()
+			fake.Errors["DescribeMetricMetaList"] = tt.err
+			metrics := client.NewMetrics("alicloud", nil)
+			c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+				cfg.Retry = config.RetryConfig{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+			})
+			c.SetMetrics(metrics)
+
+			err := c.Health(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Health() error = %v, want error %v", err, tt.wantErr)
+			}
+			if calls := fake.Calls("DescribeMetricMetaList"); calls != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", calls, tt.wantRequests)
+			}
+			if err := testutil.CollectAndCompare(metrics, strings.NewReader(tt.wantMetrics), "alicloud_api_requests_total", "alicloud_api_retries_total"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWithRetryGivesUpBeforeDeadline(t *testing.T) {
+	fake := clienttest.New()
+	fake.Errors["DescribeMetricMetaList"] = &clienttest.APIError{Status: 400, Code: "Throttling.User"}
+	c := newTestClient(t, fake, func(cfg *config.AlicloudConfig) {
+		cfg.Retry = config.RetryConfig{MaxAttempts: 5, BaseBackoff: time.Second, MaxBackoff: time.Second}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Health(ctx)
+	if client.ErrorCode(err) != "Throttling.User" {
+		t.Fatalf("Health() error = %v, want the throttling error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Health() returned after %v, want no wait for a backoff beyond the deadline", elapsed)
+	}
+	if calls := fake.Calls("DescribeMetricMetaList"); calls != 1 {
+		t.Errorf("got %d requests, want 1", calls)
+	}
+}
@@ -0,0 +1,390 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"alicloud-exporter/internal/config"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/r_kvstore"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/rds"
+)
+
+const (
+	// rdsTagBatchSize is the maximum number of resource IDs per ListTagResources request
+	rdsTagBatchSize = 50
+
+	// redisTagBatchSize is the maximum number of instance IDs per DescribeInstances request
+	redisTagBatchSize = 30
+
+	// defaultTagTTL and defaultNegativeTagTTL apply when the tag cache TTLs are not configured
+	defaultTagTTL         = 5 * time.Minute
+	defaultNegativeTagTTL = time.Minute
+
+	// tagRefreshTimeout bounds the background refresh of stale tags
+	tagRefreshTimeout = time.Minute
+)
+
+// TagCache caches the tags and region of instances. Entries expire after the TTL, or the
+// negative TTL for unknown instances, and expired tags are still served during the stale period
+// while they are refreshed in the background. Least recently used entries are evicted beyond the
+// maximum number of entries.
+type TagCache struct {
+	entries     map[string]*list.Element
+	lru         *list.List // Most recently used entries first
+	ttl         time.Duration
+	negativeTTL time.Duration
+	staleTTL    time.Duration
+	maxEntries  int
+	metrics     *Metrics
+	mu          sync.Mutex
+}
+
+// tagEntry is a cached instance
+type tagEntry struct {
+	key        string
+	tags       map[string]string
+	region     string
+	negative   bool
+	expiration time.Time
+	refreshing bool
+}
+
+// NewTagCache creates a tag cache, zero TTLs fall back to the defaults
+func NewTagCache(cfg config.TagCacheConfig) *TagCache {
+	tc := &TagCache{
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		ttl:         cfg.TTL,
+		negativeTTL: cfg.NegativeTTL,
+		staleTTL:    cfg.StaleTTL,
+		maxEntries:  cfg.MaxEntries,
+	}
+	if tc.ttl == 0 {
+		tc.ttl = defaultTagTTL
+	}
+	if tc.negativeTTL == 0 {
+		tc.negativeTTL = defaultNegativeTagTTL
+	}
+	return tc
+}
+
+// Get retrieves the tags of an instance from the cache
+func (tc *TagCache) Get(key string) (map[string]string, bool) {
+	tags, _, found, _ := tc.lookup(key, false)
+	return tags, found
+}
+
+// GetWithRegion retrieves the tags and region of an instance from the cache
+func (tc *TagCache) GetWithRegion(key string) (map[string]string, string, bool) {
+	tags, region, found, _ := tc.lookup(key, false)
+	return tags, region, found
+}
+
+// Lookup retrieves the tags and region of an instance from the cache. Refresh is set for stale
+// entries, only for the first caller, which should refresh them in the background.
+func (tc *TagCache) Lookup(key string) (tags map[string]string, region string, found, refresh bool) {
+	return tc.lookup(key, true)
+}
+
+// lookup retrieves an entry, marking the entry as refreshing if claim is set and it is stale
+func (tc *TagCache) lookup(key string, claim bool) (map[string]string, string, bool, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	element, found := tc.entries[key]
+	if !found {
+		tc.metrics.recordTagCacheMiss()
+		return nil, "", false, false
+	}
+
+	entry := element.Value.(*tagEntry)
+	now := time.Now()
+	if now.Before(entry.expiration) {
+		tc.lru.MoveToFront(element)
+		tc.metrics.recordTagCacheHit("fresh")
+		return entry.tags, entry.region, true, false
+	}
+
+	// Unknown instances are looked up again as soon as they expire, they may have been created
+	if entry.negative || !now.Before(entry.expiration.Add(tc.staleTTL)) {
+		tc.remove(element)
+		tc.metrics.recordTagCacheMiss()
+		return nil, "", false, false
+	}
+
+	tc.lru.MoveToFront(element)
+	tc.metrics.recordTagCacheHit("stale")
+	refresh := claim && !entry.refreshing
+	if refresh {
+		entry.refreshing = true
+	}
+	return entry.tags, entry.region, true, refresh
+}
+
+// Set stores the tags of an instance
+func (tc *TagCache) Set(key string, tags map[string]string) {
+	tc.SetWithRegion(key, tags, "")
+}
+
+// SetWithRegion stores the tags and region of an instance
+func (tc *TagCache) SetWithRegion(key string, tags map[string]string, region string) {
+	tc.set(&tagEntry{key: key, tags: tags, region: region, expiration: time.Now().Add(tc.ttl)})
+}
+
+// SetMissing caches an instance that wasn't found with empty tags for the negative TTL
+func (tc *TagCache) SetMissing(key string) {
+	tc.set(&tagEntry{key: key, tags: make(map[string]string), negative: true, expiration: time.Now().Add(tc.negativeTTL)})
+}
+
+// RefreshDone releases the refresh claimed on entries by Lookup, so entries that couldn't be
+// refreshed are retried by the next lookup
+func (tc *TagCache) RefreshDone(keys []string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	for _, key := range keys {
+		if element, found := tc.entries[key]; found {
+			element.Value.(*tagEntry).refreshing = false
+		}
+	}
+}
+
+// Len returns the number of cached instances
+func (tc *TagCache) Len() int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.lru.Len()
+}
+
+// set stores an entry, evicting the least recently used entries beyond the maximum
+func (tc *TagCache) set(entry *tagEntry) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if element, found := tc.entries[entry.key]; found {
+		element.Value = entry
+		tc.lru.MoveToFront(element)
+		return
+	}
+
+	tc.entries[entry.key] = tc.lru.PushFront(entry)
+	for tc.maxEntries > 0 && tc.lru.Len() > tc.maxEntries {
+		tc.remove(tc.lru.Back())
+		tc.metrics.recordTagCacheEviction()
+	}
+}
+
+// remove deletes an entry, the caller must hold tc.mu
+func (tc *TagCache) remove(element *list.Element) {
+	tc.lru.Remove(element)
+	delete(tc.entries, element.Value.(*tagEntry).key)
+}
+
+// setMetrics attaches the metrics recording cache hits, misses and evictions
+func (tc *TagCache) setMetrics(metrics *Metrics) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.metrics = metrics
+}
+
+// tagLookup looks up the tags of a batch of instances in a region
+type tagLookup func(ctx context.Context, region string, batch []string) (map[string]map[string]string, error)
+
+// GetRDSInstanceTags retrieves tags for RDS instances in a region using the ListTagResources API
+func (c *Client) GetRDSInstanceTags(ctx context.Context, region string, instanceIDs []string) (map[string]map[string]string, error) {
+	return c.getInstanceTags(ctx, region, instanceIDs, func(ctx context.Context, region string, batch []string) (map[string]map[string]string, error) {
+		rdsClient, exists := c.rdsClients[region]
+		if !exists {
+			return nil, fmt.Errorf("no RDS client configured for region %s", region)
+		}
+		return c.listRDSTags(ctx, rdsClient, batch)
+	}, "rds", rdsTagBatchSize)
+}
+
+// GetRedisInstanceTags retrieves tags for Redis (KVStore) instances in a region using the
+// DescribeInstances API
+func (c *Client) GetRedisInstanceTags(ctx context.Context, region string, instanceIDs []string) (map[string]map[string]string, error) {
+	return c.getInstanceTags(ctx, region, instanceIDs, func(ctx context.Context, region string, batch []string) (map[string]map[string]string, error) {
+		kvClient, exists := c.kvClients[region]
+		if !exists {
+			return nil, fmt.Errorf("no Redis client configured for region %s", region)
+		}
+		return c.describeRedisTags(ctx, kvClient, batch)
+	}, "redis", redisTagBatchSize)
+}
+
+// getInstanceTags resolves tags for instances of a region through the tag cache, looking up the
+// instances that are not cached yet. Stale tags are returned as is and refreshed in the background.
+func (c *Client) getInstanceTags(ctx context.Context, region string, instanceIDs []string, lookup tagLookup, service string, batchSize int) (map[string]map[string]string, error) {
+	tagsMap := make(map[string]map[string]string)
+	var uncachedIDs, staleIDs []string
+
+	// Check cache first to avoid unnecessary API calls
+	for _, id := range instanceIDs {
+		tags, _, found, refresh := c.tagCache.Lookup(id)
+		if !found {
+			uncachedIDs = append(uncachedIDs, id)
+			continue
+		}
+		tagsMap[id] = tags
+		if refresh {
+			staleIDs = append(staleIDs, id)
+		}
+	}
+
+	c.refreshTags(staleIDs, func(ctx context.Context, ids []string) error {
+		_, err := c.fetchInstanceTags(ctx, region, ids, lookup, service, batchSize)
+		return err
+	})
+
+	found, err := c.fetchInstanceTags(ctx, region, uncachedIDs, lookup, service, batchSize)
+	for id, tags := range found {
+		tagsMap[id] = tags
+	}
+	return tagsMap, err
+}
+
+// fetchInstanceTags resolves the tags of instances of a service in their region and caches them.
+// Tags resolved by the inventory listing are taken from it, the other instances are looked up in
+// batches. The instances of failed batches are left uncached.
+func (c *Client) fetchInstanceTags(ctx context.Context, region string, instanceIDs []string, lookup tagLookup, service string, batchSize int) (map[string]map[string]string, error) {
+	tagsMap := make(map[string]map[string]string)
+	if len(instanceIDs) == 0 {
+		return tagsMap, nil
+	}
+
+	listed := c.inventoryInstances(service)
+	var lookupIDs []string
+	for _, id := range instanceIDs {
+		instance, found := listed[id]
+		if found && instance.Region == region && instance.Tags != nil {
+			tagsMap[id] = instance.Tags
+			c.tagCache.SetWithRegion(id, instance.Tags, region)
+			continue
+		}
+		lookupIDs = append(lookupIDs, id)
+	}
+
+	var errs []error
+	for start := 0; start < len(lookupIDs); start += batchSize {
+		end := start + batchSize
+		if end > len(lookupIDs) {
+			end = len(lookupIDs)
+		}
+
+		batch := lookupIDs[start:end]
+		found, err := lookup(ctx, region, batch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("region %s: %w", region, err))
+			continue
+		}
+
+		for _, id := range batch {
+			if tags, exists := found[id]; exists {
+				tagsMap[id] = tags
+				c.tagCache.SetWithRegion(id, tags, region)
+				continue
+			}
+
+			// Instances without tags are absent from ListTagResources. Those listed in the
+			// inventory exist and are cached with empty tags for the TTL, unknown instances are
+			// cached for the negative TTL to avoid repeated API calls.
+			tagsMap[id] = make(map[string]string)
+			if instance, found := listed[id]; found && instance.Region == region {
+				c.tagCache.SetWithRegion(id, tagsMap[id], region)
+			} else {
+				c.tagCache.SetMissing(id)
+			}
+		}
+	}
+
+	return tagsMap, errors.Join(errs...)
+}
+
+// refreshTags refreshes the stale tags of instances in the background, until the client is
+// closed. Failed refreshes are counted in the API metrics and retried by the next lookup.
+func (c *Client) refreshTags(instanceIDs []string, refresh func(ctx context.Context, instanceIDs []string) error) {
+	if len(instanceIDs) == 0 {
+		return
+	}
+
+	go func() {
+		defer c.tagCache.RefreshDone(instanceIDs)
+
+		ctx, cancel := context.WithTimeout(c.background, tagRefreshTimeout)
+		defer cancel()
+		if err := refresh(ctx, instanceIDs); err != nil && c.background.Err() == nil {
+			c.getLogger().WithField("instances", len(instanceIDs)).WithError(err).Warn("Failed to refresh instance tags")
+		}
+	}()
+}
+
+// listRDSTags lists the tags of a batch of RDS instances, following NextToken
+func (c *Client) listRDSTags(ctx context.Context, rdsClient *rds.Client, instanceIDs []string) (map[string]map[string]string, error) {
+	request := rds.CreateListTagResourcesRequest()
+	request.Scheme = c.scheme("rds")
+	request.ResourceType = "INSTANCE"
+	request.ResourceId = &instanceIDs
+
+	tagsMap := make(map[string]map[string]string)
+	for {
+		var response *rds.ListTagResourcesResponse
+		err := c.withRetry(ctx, "ListTagResources", func() error {
+			var callErr error
+			response, callErr = rdsClient.ListTagResources(request)
+			return callErr
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, resource := range response.TagResources.TagResource {
+			if _, exists := tagsMap[resource.ResourceId]; !exists {
+				tagsMap[resource.ResourceId] = make(map[string]string)
+			}
+			tagsMap[resource.ResourceId][resource.TagKey] = resource.TagValue
+		}
+
+		if response.NextToken == "" || response.NextToken == request.NextToken {
+			break
+		}
+		request.NextToken = response.NextToken
+	}
+
+	// Instances without tags are absent from ListTagResources, they are resolved by the caller
+	return tagsMap, nil
+}
+
+// describeRedisTags describes a batch of Redis instances and returns their tags
+func (c *Client) describeRedisTags(ctx context.Context, kvClient *r_kvstore.Client, instanceIDs []string) (map[string]map[string]string, error) {
+	request := r_kvstore.CreateDescribeInstancesRequest()
+	request.Scheme = c.scheme("redis")
+	request.InstanceIds = strings.Join(instanceIDs, ",")
+	request.PageSize = "50"
+
+	var response *r_kvstore.DescribeInstancesResponse
+	err := c.withRetry(ctx, "DescribeInstances", func() error {
+		var callErr error
+		response, callErr = kvClient.DescribeInstances(request)
+		return callErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tagsMap := make(map[string]map[string]string)
+	for _, instance := range response.Instances.KVStoreInstance {
+		instanceTags := make(map[string]string)
+		for _, tag := range instance.Tags.Tag {
+			instanceTags[tag.Key] = tag.Value
+		}
+		tagsMap[instance.InstanceId] = instanceTags
+	}
+
+	return tagsMap, nil
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"alicloud-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTagCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		negative    bool
+		age         time.Duration
+		wantFound   bool
+		wantRefresh bool
+	}{
+		{name: "fresh", age: 0, wantFound: true},
+		{name: "stale", age: 150 * time.Millisecond, wantFound: true, wantRefresh: true},
+		{name: "expired", age: 300 * time.Millisecond},
+		{name: "fresh negative", negative: true, age: 0, wantFound: true},
+		{name: "expired negative", negative: true, age: 150 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := NewTagCache(config.TagCacheConfig{
+				TTL:         100 * time.Millisecond,
+				NegativeTTL: 100 * time.Millisecond,
+				StaleTTL:    100 * time.Millisecond,
+			})
+			if tt.negative {
+				tc.SetMissing("i-1")
+			} else {
+				tc.SetWithRegion("i-1", map[string]string{"Team": "infra"}, "cn-hangzhou")
+			}
+
+			// Age the entry instead of sleeping
+			entry := tc.entries["i-1"].Value.(*tagEntry)
+			entry.expiration = entry.expiration.Add(-tt.age)
+
+			_, _, found, refresh := tc.Lookup("i-1")
+			if found != tt.wantFound || refresh != tt.wantRefresh {
+				t.Errorf("Lookup() found = %v, refresh = %v, want %v, %v", found, refresh, tt.wantFound, tt.wantRefresh)
+			}
+			if !tt.wantFound && tc.Len() != 0 {
+				t.Errorf("expired entry was not removed")
+			}
+		})
+	}
+}
+
+func TestTagCacheRefreshClaim(t *testing.T) {
+	tc := NewTagCache(config.TagCacheConfig{TTL: time.Minute, StaleTTL: time.Hour})
+	tc.SetWithRegion("i-1", map[string]string{"Team": "infra"}, "cn-hangzhou")
+	entry := tc.entries["i-1"].Value.(*tagEntry)
+	entry.expiration = time.Now().Add(-time.Second)
+
+	if _, _, _, refresh := tc.Lookup("i-1"); !refresh {
+		t.Fatal("first Lookup() of a stale entry should claim the refresh")
+	}
+	if _, _, _, refresh := tc.Lookup("i-1"); refresh {
+		t.Error("second Lookup() claimed a refresh in progress")
+	}
+	if _, _, found := tc.GetWithRegion("i-1"); !found {
+		t.Error("GetWithRegion() of a stale entry found = false")
+	}
+
+	tc.RefreshDone([]string{"i-1"})
+	if _, _, _, refresh := tc.Lookup("i-1"); !refresh {
+		t.Error("Lookup() after a failed refresh should claim the refresh again")
+	}
+}
+
+func TestTagCacheEviction(t *testing.T) {
+	metrics := NewMetrics("alicloud", nil)
+	tc := NewTagCache(config.TagCacheConfig{TTL: time.Minute, MaxEntries: 2})
+	tc.setMetrics(metrics)
+
+	tc.Set("i-1", map[string]string{})
+	tc.Set("i-2", map[string]string{})
+	tc.Get("i-1") // i-2 becomes the least recently used
+	tc.Set("i-3", map[string]string{})
+
+	if _, found := tc.Get("i-2"); found {
+		t.Error("least recently used entry i-2 was not evicted")
+	}
+	for _, id := range []string{"i-1", "i-3"} {
+		if _, found := tc.Get(id); !found {
+			t.Errorf("entry %s was evicted", id)
+		}
+	}
+	if tc.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", tc.Len())
+	}
+
+	if got := testutil.ToFloat64(metrics.tagCacheEvictions); got != 1 {
+		t.Errorf("evictions = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.tagCacheMisses); got != 1 {
+		t.Errorf("misses = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.tagCacheHits.WithLabelValues("fresh")); got != 3 {
+		t.Errorf("fresh hits = %v, want 3", got)
+	}
+}
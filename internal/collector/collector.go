@@ -6,8 +6,10 @@ import (
 	"alicloud-exporter/internal/logger"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
+	"regexp"
 	"sync"
 	"time"
 )
@@ -27,6 +29,45 @@ type MetricData struct {
 	Maximum    float64 `json:"Maximum"`
 	Average    float64 `json:"Average"`
 	Minimum    float64 `json:"Minimum"`
+	Value      float64 `json:"Value"`
+
+	// Dimensions holds every string-valued field of the datapoint, keyed by its CMS name
+	Dimensions map[string]string `json:"-"`
+
+	// Statistics holds every numeric field of the datapoint except the timestamp, such as
+	// Average, Maximum, Minimum, Sum, Value or percentiles like p99
+	Statistics map[string]float64 `json:"-"`
+}
+
+// UnmarshalJSON decodes a datapoint and collects its string-valued fields into Dimensions
+// and its numeric fields into Statistics
+func (m *MetricData) UnmarshalJSON(data []byte) error {
+	type metricData MetricData
+	var decoded metricData
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	decoded.Dimensions = make(map[string]string)
+	decoded.Statistics = make(map[string]float64)
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			decoded.Dimensions[key] = v
+		case float64:
+			if key != "timestamp" {
+				decoded.Statistics[key] = v
+			}
+		}
+	}
+
+	*m = MetricData(decoded)
+	return nil
 }
 
 // ServiceCollector defines the interface for service-specific collectors
@@ -44,24 +85,40 @@ type ServiceCollector interface {
 	Enabled() bool
 }
 
+// SourceMetricResolver is implemented by collectors that can tell the CMS metric a collected
+// sample was exported from
+type SourceMetricResolver interface {
+	// SourceMetric returns the CMS metric of a descriptor, false for other metrics
+	SourceMetric(desc *prometheus.Desc) (string, bool)
+}
+
 // BaseCollector provides common functionality for all collectors
 type BaseCollector struct {
-	client         *client.Client
+	client         client.API
 	config         config.ServiceConfig
 	logger         *logger.Logger
 	serviceName    string
-	metricDescs    map[string]*prometheus.Desc
+	metricDescs    map[string][]metricSeries
 	globalLabels   map[string]string
 	metricPrefix   string
 	mu             sync.RWMutex
 	lastScrape     time.Time
 	scrapeErrors   prometheus.Counter
 	scrapeDuration prometheus.Histogram
+	staleDropped   *prometheus.CounterVec
+	fetchTags      tagFetcher
+	inventory      instanceDescriber
+	dimensions     []client.Dimension
+	metricNames    []string
+	selectors      []*regexp.Regexp
+	metricMeta     map[string]client.MetricMeta
+	sourceMetrics  map[*prometheus.Desc]string
+	infoDesc       *prometheus.Desc
 }
 
 // NewBaseCollector creates a new base collector
 func NewBaseCollector(
-	client *client.Client,
+	client client.API,
 	config config.ServiceConfig,
 	serviceName string,
 	globalLabels map[string]string,
@@ -69,13 +126,14 @@ func NewBaseCollector(
 	log *logger.Logger,
 ) *BaseCollector {
 	bc := &BaseCollector{
-		client:       client,
-		config:       config,
-		logger:       log,
-		serviceName:  serviceName,
-		metricDescs:  make(map[string]*prometheus.Desc),
-		globalLabels: globalLabels,
-		metricPrefix: metricPrefix,
+		client:        client,
+		config:        config,
+		logger:        log,
+		serviceName:   serviceName,
+		metricDescs:   make(map[string][]metricSeries),
+		sourceMetrics: make(map[*prometheus.Desc]string),
+		globalLabels:  globalLabels,
+		metricPrefix:  metricPrefix,
 		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
 			Name:        prometheus.BuildFQName(metricPrefix, serviceName, "scrape_errors_total"),
 			Help:        fmt.Sprintf("Total number of scrape errors for %s service", serviceName),
@@ -87,40 +145,102 @@ func NewBaseCollector(
 			ConstLabels: globalLabels,
 			Buckets:     prometheus.DefBuckets,
 		}),
+		staleDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(metricPrefix, "", "stale_datapoints_dropped_total"),
+			Help:        "Total number of CMS datapoints dropped for being older than max_datapoint_age.",
+			ConstLabels: withLabel(globalLabels, "service", serviceName),
+		}, []string{"metric"}),
+	}
+
+	// Look up tags through the describe API of the service, if it has one
+	switch serviceName {
+	case "slb":
+		// SLB tags come from the inventory of every region
+		bc.fetchTags = func(ctx context.Context, _ string, instanceIDs []string) (map[string]map[string]string, error) {
+			return client.GetSLBInstanceTags(ctx, instanceIDs)
+		}
+		bc.inventory = client.DescribeSLBInstances
+	case "rds":
+		bc.fetchTags = client.GetRDSInstanceTags
+		bc.inventory = client.DescribeRDSInstances
+	case "redis":
+		bc.fetchTags = client.GetRedisInstanceTags
+		bc.inventory = client.DescribeRedisInstances
 	}
 
+	// Dimension filters are validated when the configuration is loaded
+	dimensions, err := dimensionFilters(config)
+	if err != nil {
+		log.WithService(serviceName).WithError(err).Error("Ignoring invalid dimension filters")
+	}
+	bc.dimensions = dimensions
+
+	// Metrics are either named or selected from the namespace by pattern
+	bc.metricNames, bc.selectors = metricSelectors(config.Metrics)
+
 	// Initialize metric descriptors
 	bc.initMetricDescriptors()
+	bc.initInfoDescriptor()
 
 	return bc
 }
 
-// initMetricDescriptors initializes Prometheus metric descriptors
+// initMetricDescriptors initializes Prometheus metric descriptors of the named metrics, those of
+// selected metrics are created once they are discovered
 func (bc *BaseCollector) initMetricDescriptors() {
-	for _, metricName := range bc.config.Metrics {
-		// Base labels that are always present
-		labels := []string{"instance_id"}
-
-		// Add service-specific labels (only non-empty ones will be used)
-		switch bc.serviceName {
-		case "slb":
-			// For SLB, we'll create descriptors dynamically with tags and region
-			labels = append(labels, "protocol", "port", "vip", "region")
-		case "redis":
-			// Redis - only add labels that might have values
-			// We'll filter empty values in buildLabelValues
-		case "rds":
-			// RDS - only add labels that might have values
-			// We'll filter empty values in buildLabelValues
+	labels := bc.labelNames()
+
+	for _, metricName := range bc.metricNames {
+		for _, stat := range bc.descStatistics(metricName) {
+			bc.metricDescs[bc.descKey(metricName, stat)] = bc.newSeries(metricName, stat, labels)
 		}
+	}
+}
+
+// dimensionFilters converts the configured dimension filters to CMS dimensions
+func dimensionFilters(cfg config.ServiceConfig) ([]client.Dimension, error) {
+	filters, err := cfg.DimensionFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	dimensions := make([]client.Dimension, 0, len(filters))
+	for _, filter := range filters {
+		dimensions = append(dimensions, client.Dimension(filter))
+	}
+	return dimensions, nil
+}
+
+// labelNames returns the variable labels of the service metrics
+func (bc *BaseCollector) labelNames() []string {
+	// Base labels that are always present
+	labels := []string{"instance_id"}
+
+	// Add service-specific labels (only non-empty ones will be used)
+	switch bc.serviceName {
+	case "slb":
+		labels = append(labels, "protocol", "port", "vip", "region")
+	default:
+		labels = append(labels, "region")
+	}
 
-		bc.metricDescs[metricName] = prometheus.NewDesc(
-			prometheus.BuildFQName(bc.metricPrefix, bc.serviceName, metricName),
-			fmt.Sprintf("%s metric from Alicloud CMS", metricName),
-			labels,
-			bc.globalLabels,
-		)
+	// Configured dimensions follow for every service, mapped tags come last
+	for _, dimension := range bc.dimensionLabels() {
+		labels = append(labels, config.DimensionLabelName(dimension))
+	}
+	return append(labels, bc.tagLabelNames()...)
+}
+
+// dimensionLabels returns the configured dimensions to export as labels, excluding instanceId
+func (bc *BaseCollector) dimensionLabels() []string {
+	dimensions := make([]string, 0, len(bc.config.DimensionsAsLabels))
+	for _, dimension := range bc.config.DimensionsAsLabels {
+		if config.DimensionLabelName(dimension) == "instance_id" {
+			continue
+		}
+		dimensions = append(dimensions, dimension)
 	}
+	return dimensions
 }
 
 // Name returns the service name
@@ -138,80 +258,170 @@ func (bc *BaseCollector) Describe(ch chan<- *prometheus.Desc) {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
 
-	for _, desc := range bc.metricDescs {
-		ch <- desc
+	for _, series := range bc.metricDescs {
+		for _, s := range series {
+			ch <- s.desc
+		}
+	}
+	if bc.infoDesc != nil {
+		ch <- bc.infoDesc
 	}
 
 	// Send internal metrics descriptors
 	ch <- bc.scrapeErrors.Desc()
 	ch <- bc.scrapeDuration.Desc()
+	bc.staleDropped.Describe(ch)
 }
 
-// CollectMetric collects a single metric from Alicloud CMS
-func (bc *BaseCollector) CollectMetric(ctx context.Context, metricName string, ch chan<- prometheus.Metric) error {
-	response, err := bc.client.GetMetricData(ctx, bc.config.Namespace, metricName)
+// CollectInternal sends the internal metrics of the collector to the channel
+func (bc *BaseCollector) CollectInternal(ch chan<- prometheus.Metric) {
+	ch <- bc.scrapeErrors
+	ch <- bc.scrapeDuration
+	bc.staleDropped.Collect(ch)
+}
+
+// withLabel returns a copy of labels with an additional label set
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
+
+// RegionMetricData holds the datapoints of a metric fetched from a single region
+type RegionMetricData struct {
+	Region string
+	Data   []MetricData
+	Err    error
+}
+
+// FetchMetricData fetches a metric from every configured region concurrently
+func (bc *BaseCollector) FetchMetricData(ctx context.Context, metricName string) []RegionMetricData {
+	regions := bc.regions()
+	results := make([]RegionMetricData, len(regions))
+
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			data, err := bc.fetchRegionMetricData(ctx, region, metricName)
+			results[i] = RegionMetricData{Region: region, Data: data, Err: err}
+		}(i, region)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// regions returns the regions this service is collected from
+func (bc *BaseCollector) regions() []string {
+	if len(bc.config.Regions) > 0 {
+		return bc.config.Regions
+	}
+	return bc.client.GetRegions()
+}
+
+// fetchRegionMetricData fetches and decodes a metric from a single region
+func (bc *BaseCollector) fetchRegionMetricData(ctx context.Context, region, metricName string) ([]MetricData, error) {
+	response, err := bc.client.GetMetricDataInRegionWithDimensions(ctx, region, bc.config.Namespace, metricName, bc.dimensions)
 	if err != nil {
-		return fmt.Errorf("failed to get metric %s: %w", metricName, err)
+		return nil, fmt.Errorf("failed to get metric %s in region %s: %w", metricName, region, err)
 	}
 
 	if response.Datapoints == "" {
-		return nil // No data available
+		return nil, nil // No data available
 	}
 
 	var metricData []MetricData
 	if err := json.Unmarshal([]byte(response.Datapoints), &metricData); err != nil {
-		return fmt.Errorf("failed to unmarshal metric data for %s: %w", metricName, err)
+		return nil, fmt.Errorf("failed to unmarshal metric data for %s in region %s: %w", metricName, region, err)
 	}
 
-	desc, exists := bc.metricDescs[metricName]
-	if !exists {
-		return fmt.Errorf("metric descriptor not found for %s", metricName)
+	return bc.dropStale(metricName, metricData), nil
+}
+
+// dropStale removes datapoints older than the configured max age, counting the dropped ones
+func (bc *BaseCollector) dropStale(metricName string, metricData []MetricData) []MetricData {
+	if bc.config.MaxDatapointAge <= 0 {
+		return metricData
 	}
 
+	cutoff := time.Now().Add(-bc.config.MaxDatapointAge)
+	fresh := metricData[:0]
 	for _, data := range metricData {
-		value := data.Average
-		if value == 0 {
-			value = data.Maximum
+		if data.Timestamp > 0 && time.UnixMilli(data.Timestamp).Before(cutoff) {
+			bc.staleDropped.WithLabelValues(metricName).Inc()
+			continue
 		}
-		if value == 0 {
-			value = data.Sum
+		fresh = append(fresh, data)
+	}
+	return fresh
+}
+
+// regionErrors combines the errors of failed regions, logging each one
+func (bc *BaseCollector) regionErrors(metricName string, results []RegionMetricData) error {
+	var errs []error
+	for _, result := range results {
+		if result.Err == nil {
+			continue
 		}
+		bc.logger.WithFields(map[string]interface{}{
+			"metric": metricName,
+			"region": result.Region,
+		}).WithError(result.Err).Warn("Failed to collect metric in region")
+		errs = append(errs, result.Err)
+	}
 
-		labelValues := bc.buildLabelValues(data)
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to collect metric %s in %d of %d regions: %w", metricName, len(errs), len(results), errors.Join(errs...))
+	}
+	return nil
+}
 
-		metric, err := prometheus.NewConstMetric(
-			desc,
-			prometheus.GaugeValue,
-			value,
-			labelValues...,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to create metric for %s: %w", metricName, err)
+// CollectMetric collects a single metric from Alicloud CMS in every configured region
+func (bc *BaseCollector) CollectMetric(ctx context.Context, metricName string, ch chan<- prometheus.Metric) error {
+	results := bc.FetchMetricData(ctx, metricName)
+	tags := bc.instanceTags(ctx, results)
+
+	for _, result := range results {
+		for _, data := range result.Data {
+			// Label with the region the datapoint was actually queried from
+			labelValues := bc.buildLabelValues(data, result.Region)
+			labelValues = append(labelValues, bc.tagLabelValues(tags[data.InstanceID])...)
+
+			if err := bc.sendStatistics(ch, metricName, data, labelValues); err != nil {
+				return err
+			}
 		}
-
-		ch <- metric
 	}
 
-	return nil
+	// Regions that failed don't prevent the others from being exported
+	return bc.regionErrors(metricName, results)
 }
 
-// buildLabelValues builds label values based on service type and metric data
-func (bc *BaseCollector) buildLabelValues(data MetricData) []string {
+// buildLabelValues builds label values based on service type, metric data and the region it came from
+func (bc *BaseCollector) buildLabelValues(data MetricData, region string) []string {
+	var labelValues []string
 	switch bc.serviceName {
 	case "slb":
-		return []string{
+		labelValues = []string{
 			data.InstanceID,
 			data.Protocol,
 			data.Port,
 			data.Vip,
-			bc.client.GetRegion(),
+			region,
 		}
-	case "redis", "rds":
-		// Only return instance_id for redis and rds to avoid empty labels
-		return []string{data.InstanceID}
 	default:
-		return []string{data.InstanceID}
+		labelValues = []string{data.InstanceID, region}
+	}
+
+	for _, dimension := range bc.dimensionLabels() {
+		labelValues = append(labelValues, data.Dimensions[dimension])
 	}
+	return labelValues
 }
 
 // RecordScrapeError records a scrape error
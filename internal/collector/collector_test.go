@@ -0,0 +1,432 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/client/clienttest"
+	"alicloud-exporter/internal/config"
+	"alicloud-exporter/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectorFunc adapts a ServiceCollector to an unchecked prometheus.Collector
+type collectorFunc func(ch chan<- prometheus.Metric)
+
+func (f collectorFunc) Describe(chan<- *prometheus.Desc) {}
+
+func (f collectorFunc) Collect(ch chan<- prometheus.Metric) { f(ch) }
+
+// internalMetrics are the collector's own metrics, left out of the compared series
+var internalMetrics = map[string]bool{
+	"alicloud_stale_datapoints_dropped_total": true,
+}
+
+// gather collects col once and returns its series as name{label="value",...} with their values,
+// together with the collection error
+func gather(t *testing.T, col ServiceCollector) (map[string]float64, error) {
+	t.Helper()
+
+	var collectErr error
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectErr = col.Collect(context.Background(), ch)
+	}))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	series := make(map[string]float64)
+	for _, family := range families {
+		name := family.GetName()
+		if internalMetrics[name] || strings.HasSuffix(name, "_scrape_errors_total") || strings.HasSuffix(name, "_scrape_duration_seconds") {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+			}
+			sort.Strings(labels)
+			series[name+"{"+strings.Join(labels, ",")+"}"] = metric.GetGauge().GetValue()
+		}
+	}
+	return series, collectErr
+}
+
+// compareSeries reports the series missing from got, unexpected in got or with another value
+func compareSeries(t *testing.T, got, want map[string]float64) {
+	t.Helper()
+
+	for series, value := range want {
+		if gotValue, found := got[series]; !found {
+			t.Errorf("missing series %s", series)
+		} else if gotValue != value {
+			t.Errorf("series %s = %v, want %v", series, gotValue, value)
+		}
+	}
+	for series := range got {
+		if _, found := want[series]; !found {
+			t.Errorf("unexpected series %s", series)
+		}
+	}
+}
+
+func TestCollectorSeries(t *testing.T) {
+	slbDatapoint := clienttest.Datapoint{
+		"instanceId": "lb-1",
+		"port":       "80",
+		"protocol":   "http",
+		"vip":        "10.0.0.1",
+		"timestamp":  1700000000000,
+		"Average":    12.0,
+		"Maximum":    20.0,
+	}
+	slbInstance := client.Instance{
+		ID:     "lb-1",
+		Region: "cn-hangzhou",
+		Name:   "web",
+		Tags:   map[string]string{"Team": "infra", "Env": "prod"},
+	}
+
+	tests := []struct {
+		name       string
+		service    string
+		config     config.ServiceConfig
+		metas      []client.MetricMeta
+		instances  []client.Instance
+		datapoints map[string][]clienttest.Datapoint
+		errors     map[string]error
+		want       map[string]float64
+		wantErr    bool
+	}{
+		{
+			name:    "slb default tag labels",
+			service: "slb",
+			config: config.ServiceConfig{
+				Namespace: "acs_slb_dashboard",
+				Metrics:   []string{"ActiveConnection"},
+			},
+			instances:  []client.Instance{slbInstance},
+			datapoints: map[string][]clienttest.Datapoint{"ActiveConnection": {slbDatapoint}},
+			want: map[string]float64{
+				`alicloud_slb_ActiveConnection{Group="",Name="",Team="infra",instance_id="lb-1",port="80",protocol="http",region="cn-hangzhou",vip="10.0.0.1"}`:                                                           12,
+				`alicloud_slb_info{Group="",Name="",Team="infra",charge_type="",engine="",engine_version="",expire_time="",instance_id="lb-1",name="web",network_type="",region="cn-hangzhou",spec="",vpc_id="",zone=""}`: 1,
+			},
+		},
+		{
+			name:    "statistics as labels",
+			service: "slb",
+			config: config.ServiceConfig{
+				Namespace:  "acs_slb_dashboard",
+				Metrics:    []string{"ActiveConnection"},
+				Statistics: []string{"Average", "Maximum"},
+				TagLabels:  []config.TagLabelConfig{{Tag: "Env", Label: "env"}},
+			},
+			instances:  []client.Instance{slbInstance},
+			datapoints: map[string][]clienttest.Datapoint{"ActiveConnection": {slbDatapoint}},
+			want: map[string]float64{
+				`alicloud_slb_ActiveConnection{env="prod",instance_id="lb-1",port="80",protocol="http",region="cn-hangzhou",statistic="Average",vip="10.0.0.1"}`:                                       12,
+				`alicloud_slb_ActiveConnection{env="prod",instance_id="lb-1",port="80",protocol="http",region="cn-hangzhou",statistic="Maximum",vip="10.0.0.1"}`:                                       20,
+				`alicloud_slb_info{charge_type="",engine="",engine_version="",env="prod",expire_time="",instance_id="lb-1",name="web",network_type="",region="cn-hangzhou",spec="",vpc_id="",zone=""}`: 1,
+			},
+		},
+		{
+			name:    "slb dimensions as labels",
+			service: "slb",
+			config: config.ServiceConfig{
+				Namespace:          "acs_slb_dashboard",
+				Metrics:            []string{"ActiveConnection"},
+				DimensionsAsLabels: []string{"userId"},
+				TagLabels:          []config.TagLabelConfig{{Tag: "Env", Label: "env"}},
+			},
+			instances: []client.Instance{slbInstance},
+			datapoints: map[string][]clienttest.Datapoint{"ActiveConnection": {{
+				"instanceId": "lb-1",
+				"port":       "80",
+				"protocol":   "http",
+				"vip":        "10.0.0.1",
+				"userId":     "1234",
+				"Average":    12.0,
+			}}},
+			want: map[string]float64{
+				`alicloud_slb_ActiveConnection{env="prod",instance_id="lb-1",port="80",protocol="http",region="cn-hangzhou",user_id="1234",vip="10.0.0.1"}`:                                            12,
+				`alicloud_slb_info{charge_type="",engine="",engine_version="",env="prod",expire_time="",instance_id="lb-1",name="web",network_type="",region="cn-hangzhou",spec="",vpc_id="",zone=""}`: 1,
+			},
+		},
+		{
+			name:    "statistics as suffixes",
+			service: "ecs",
+			config: config.ServiceConfig{
+				Namespace:      "acs_ecs_dashboard",
+				Metrics:        []string{"CPUUtilization"},
+				Statistics:     []string{"Average", "Maximum"},
+				StatisticsMode: config.StatisticsModeSuffix,
+			},
+			datapoints: map[string][]clienttest.Datapoint{"CPUUtilization": {
+				{"instanceId": "i-1", "timestamp": 1700000000000, "Average": 40.0, "Maximum": 90.0},
+			}},
+			want: map[string]float64{
+				`alicloud_ecs_CPUUtilization_average{instance_id="i-1",region="cn-hangzhou"}`: 40,
+				`alicloud_ecs_CPUUtilization_maximum{instance_id="i-1",region="cn-hangzhou"}`: 90,
+			},
+		},
+		{
+			name:    "idiomatic names convert units",
+			service: "ecs",
+			config: config.ServiceConfig{
+				Namespace: "acs_ecs_dashboard",
+				Metrics:   []string{"CPUUtilization"},
+				Naming:    config.NamingIdiomatic,
+			},
+			metas: []client.MetricMeta{{MetricName: "CPUUtilization", Unit: "%"}},
+			datapoints: map[string][]clienttest.Datapoint{"CPUUtilization": {
+				{"instanceId": "i-1", "timestamp": 1700000000000, "Average": 40.0},
+			}},
+			want: map[string]float64{
+				`alicloud_ecs_cpu_utilization_ratio{instance_id="i-1",region="cn-hangzhou"}`: 0.4,
+			},
+		},
+		{
+			name:    "selectors and dimensions as labels",
+			service: "ecs",
+			config: config.ServiceConfig{
+				Namespace:          "acs_ecs_dashboard",
+				Metrics:            []string{"disk_.*"},
+				DimensionsAsLabels: []string{"device"},
+			},
+			metas: []client.MetricMeta{
+				{MetricName: "disk_readbytes"},
+				{MetricName: "CPUUtilization"},
+			},
+			datapoints: map[string][]clienttest.Datapoint{
+				"disk_readbytes": {{"instanceId": "i-1", "device": "/dev/vda1", "Average": 100.0}},
+				"CPUUtilization": {{"instanceId": "i-1", "Average": 40.0}},
+			},
+			want: map[string]float64{
+				`alicloud_ecs_disk_readbytes{device="/dev/vda1",instance_id="i-1",region="cn-hangzhou"}`: 100,
+			},
+		},
+		{
+			name:    "dimension filters",
+			service: "ecs",
+			config: config.ServiceConfig{
+				Namespace:  "acs_ecs_dashboard",
+				Metrics:    []string{"CPUUtilization"},
+				Dimensions: []string{"instanceId=i-2"},
+			},
+			datapoints: map[string][]clienttest.Datapoint{"CPUUtilization": {
+				{"instanceId": "i-1", "Average": 40.0},
+				{"instanceId": "i-2", "Average": 60.0},
+			}},
+			want: map[string]float64{
+				`alicloud_ecs_CPUUtilization{instance_id="i-2",region="cn-hangzhou"}`: 60,
+			},
+		},
+		{
+			name:    "failed metric",
+			service: "ecs",
+			config: config.ServiceConfig{
+				Namespace: "acs_ecs_dashboard",
+				Metrics:   []string{"CPUUtilization", "memory_usedutilization"},
+			},
+			datapoints: map[string][]clienttest.Datapoint{
+				"CPUUtilization":         {{"instanceId": "i-1", "Average": 40.0}},
+				"memory_usedutilization": {{"instanceId": "i-1", "Average": 70.0}},
+			},
+			errors: map[string]error{"acs_ecs_dashboard/memory_usedutilization": errors.New("throttled")},
+			want: map[string]float64{
+				`alicloud_ecs_CPUUtilization{instance_id="i-1",region="cn-hangzhou"}`: 40,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clienttest.New()
+			fake.Metas[tt.config.Namespace] = tt.metas
+			fake.Instances[tt.service] = tt.instances
+			for metricName, datapoints := range tt.datapoints {
+				fake.AddDatapoints("cn-hangzhou", tt.config.Namespace, metricName, datapoints...)
+			}
+			for target, err := range tt.errors {
+				fake.Errors[target] = err
+			}
+
+			tt.config.Enabled = true
+			log := logger.New("error", "text")
+			var col ServiceCollector
+			if tt.service == "slb" {
+				col = NewSLBCollector(fake, tt.config, nil, "alicloud", log)
+			} else {
+				col = NewGenericCollector(fake, tt.config, tt.service, nil, "alicloud", log)
+			}
+
+			got, err := gather(t, col)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Collect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			compareSeries(t, got, tt.want)
+		})
+	}
+}
+
+func TestCollectorHelpFromMetricMeta(t *testing.T) {
+	tests := []struct {
+		name     string
+		metaErr  error
+		wantHelp string
+	}{
+		{name: "discovered metadata", wantHelp: "CPU usage, unit % (CMS acs_ecs_dashboard/CPUUtilization)"},
+		{name: "metadata call failed", metaErr: errors.New("throttled"), wantHelp: "CPUUtilization metric from Alicloud CMS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clienttest.New()
+			fake.Metas["acs_ecs_dashboard"] = []client.MetricMeta{
+				{Namespace: "acs_ecs_dashboard", MetricName: "CPUUtilization", Description: "CPU usage", Unit: "%"},
+			}
+			if tt.metaErr != nil {
+				fake.Errors["DescribeMetricMeta"] = tt.metaErr
+			}
+			fake.AddDatapoints("cn-hangzhou", "acs_ecs_dashboard", "CPUUtilization", clienttest.Datapoint{"instanceId": "i-1", "Average": 40.0})
+
+			col := NewGenericCollector(fake, config.ServiceConfig{
+				Enabled:   true,
+				Namespace: "acs_ecs_dashboard",
+				Metrics:   []string{"CPUUtilization"},
+			}, "ecs", nil, "alicloud", logger.New("error", "text"))
+
+			// The configured metric is collected either way
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(collectorFunc(func(ch chan<- prometheus.Metric) {
+				col.Collect(context.Background(), ch)
+			}))
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatalf("Gather() error = %v", err)
+			}
+			var help string
+			for _, family := range families {
+				if family.GetName() == "alicloud_ecs_CPUUtilization" {
+					help = family.GetHelp()
+				}
+			}
+			if help != tt.wantHelp {
+				t.Errorf("help = %q, want %q", help, tt.wantHelp)
+			}
+		})
+	}
+}
+
+func TestCollectorTagsLookedUpInMetricRegion(t *testing.T) {
+	fake := clienttest.New("cn-hangzhou", "cn-shanghai")
+	fake.Instances["rds"] = []client.Instance{
+		{ID: "rm-1", Region: "cn-hangzhou", Tags: map[string]string{"Team": "orders"}},
+		{ID: "rm-2", Region: "cn-shanghai", Tags: map[string]string{"Team": "billing"}},
+	}
+	fake.AddDatapoints("cn-hangzhou", "acs_rds_dashboard", "CpuUsage", clienttest.Datapoint{"instanceId": "rm-1", "Average": 10.0})
+	fake.AddDatapoints("cn-shanghai", "acs_rds_dashboard", "CpuUsage", clienttest.Datapoint{"instanceId": "rm-2", "Average": 20.0})
+
+	col := NewRDSCollector(fake, config.ServiceConfig{
+		Enabled:   true,
+		Metrics:   []string{"CpuUsage"},
+		TagLabels: []config.TagLabelConfig{{Tag: "Team", Label: "team"}},
+	}, nil, "alicloud", logger.New("error", "text"))
+
+	got, err := gather(t, col)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	for series, value := range map[string]float64{
+		`alicloud_rds_CpuUsage{instance_id="rm-1",region="cn-hangzhou",team="orders"}`:  10,
+		`alicloud_rds_CpuUsage{instance_id="rm-2",region="cn-shanghai",team="billing"}`: 20,
+	} {
+		if got[series] != value {
+			t.Errorf("series %s = %v, want %v", series, got[series], value)
+		}
+	}
+
+	// Each region is asked for its own instances only
+	if calls := fake.Calls("GetRDSInstanceTags"); calls != 2 {
+		t.Errorf("got %d GetRDSInstanceTags calls, want one per region", calls)
+	}
+}
+
+func TestCollectorSourceMetric(t *testing.T) {
+	fake := clienttest.New()
+	fake.AddDatapoints("cn-hangzhou", "acs_ecs_dashboard", "CPUUtilization",
+		clienttest.Datapoint{"instanceId": "i-1", "Average": 40.0})
+
+	col := NewGenericCollector(fake, config.ServiceConfig{
+		Enabled:   true,
+		Namespace: "acs_ecs_dashboard",
+		Metrics:   []string{"CPUUtilization"},
+	}, "ecs", nil, "alicloud", logger.New("error", "text"))
+
+	ch := make(chan prometheus.Metric, 16)
+	if err := col.Collect(context.Background(), ch); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	close(ch)
+
+	sources := make(map[string]int)
+	for metric := range ch {
+		if metricName, found := col.SourceMetric(metric.Desc()); found {
+			sources[metricName]++
+		}
+	}
+	if len(sources) != 1 || sources["CPUUtilization"] != 1 {
+		t.Errorf("SourceMetric() resolved %v, want one CPUUtilization sample", sources)
+	}
+}
+
+// TestSLBCollectorReplay replays the API responses of an SLB scrape recorded with --record-dir in
+// testdata/slb through the real client
+func TestSLBCollectorReplay(t *testing.T) {
+	c, err := client.NewClient(&config.AlicloudConfig{
+		Region:         "cn-hangzhou",
+		RateLimit:      config.RateLimitConfig{RequestsPerSecond: 1000, Burst: 1000},
+		Retry:          config.RetryConfig{MaxAttempts: 1},
+		MetricPageSize: 1000,
+		Credentials:    config.CredentialsConfig{Type: "access_key"},
+		ReplayDir:      "testdata/slb",
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	col := NewSLBCollector(c, config.ServiceConfig{
+		Enabled:    true,
+		Namespace:  "acs_slb_dashboard",
+		Metrics:    []string{"ActiveConnection", "InstanceQps"},
+		Statistics: []string{"Average", "Maximum"},
+	}, nil, "alicloud", logger.New("error", "text"))
+
+	got, err := gather(t, col)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	want := map[string]float64{
+		`alicloud_slb_ActiveConnection{Group="frontend",Name="",Team="web",instance_id="lb-bp1",port="443",protocol="https",region="cn-hangzhou",statistic="Average",vip="47.96.0.10"}`:                                                                        125,
+		`alicloud_slb_ActiveConnection{Group="frontend",Name="",Team="web",instance_id="lb-bp1",port="443",protocol="https",region="cn-hangzhou",statistic="Maximum",vip="47.96.0.10"}`:                                                                        180,
+		`alicloud_slb_ActiveConnection{Group="",Name="",Team="api",instance_id="lb-bp2",port="80",protocol="http",region="cn-hangzhou",statistic="Average",vip="10.0.1.20"}`:                                                                                   7,
+		`alicloud_slb_ActiveConnection{Group="",Name="",Team="api",instance_id="lb-bp2",port="80",protocol="http",region="cn-hangzhou",statistic="Maximum",vip="10.0.1.20"}`:                                                                                   12,
+		`alicloud_slb_InstanceQps{Group="frontend",Name="",Team="web",instance_id="lb-bp1",port="",protocol="",region="cn-hangzhou",statistic="Average",vip=""}`:                                                                                               310.5,
+		`alicloud_slb_InstanceQps{Group="frontend",Name="",Team="web",instance_id="lb-bp1",port="",protocol="",region="cn-hangzhou",statistic="Maximum",vip=""}`:                                                                                               402,
+		`alicloud_slb_info{Group="frontend",Name="",Team="web",charge_type="PayOnDemand",engine="",engine_version="",expire_time="",instance_id="lb-bp1",name="web-public",network_type="classic",region="cn-hangzhou",spec="slb.s2.small",vpc_id="",zone=""}`: 1,
+		`alicloud_slb_info{Group="",Name="",Team="api",charge_type="",engine="",engine_version="",expire_time="",instance_id="lb-bp2",name="api-internal",network_type="vpc",region="cn-hangzhou",spec="",vpc_id="vpc-bp1",zone=""}`:                           1,
+	}
+	compareSeries(t, got, want)
+}
@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricSelectors splits the configured metrics into plain metric names and compiled selectors.
+// Selectors are validated when the configuration is loaded, invalid ones are skipped.
+func metricSelectors(metrics []string) ([]string, []*regexp.Regexp) {
+	var (
+		names     []string
+		selectors []*regexp.Regexp
+	)
+	for _, metric := range metrics {
+		selector, err := config.MetricSelector(metric)
+		switch {
+		case err != nil:
+			continue
+		case selector != nil:
+			selectors = append(selectors, selector)
+		default:
+			names = append(names, metric)
+		}
+	}
+	return names, selectors
+}
+
+// Metrics returns the metrics to collect: the configured metric names plus every metric of the
+// namespace matching a selector. Metadata is refreshed from DescribeMetricMetaList on the way,
+// when it can't be fetched only the configured names are collected.
+func (bc *BaseCollector) Metrics(ctx context.Context) []string {
+	metas, err := bc.client.DescribeMetricMeta(ctx, bc.config.Namespace)
+	if err != nil {
+		bc.logger.WithService(bc.serviceName).WithError(err).Warn("Failed to discover metrics, collecting configured metric names only")
+		return bc.metricNames
+	}
+	bc.setMetricMeta(metas)
+
+	metrics := append([]string{}, bc.metricNames...)
+	if len(bc.selectors) == 0 {
+		return metrics
+	}
+
+	selected := make(map[string]bool, len(metrics))
+	for _, metric := range metrics {
+		selected[metric] = true
+	}
+	for _, meta := range metas {
+		if selected[meta.MetricName] {
+			continue
+		}
+		for _, selector := range bc.selectors {
+			if selector.MatchString(meta.MetricName) {
+				selected[meta.MetricName] = true
+				metrics = append(metrics, meta.MetricName)
+				break
+			}
+		}
+	}
+	return metrics
+}
+
+// setMetricMeta stores the metric metadata of the namespace, dropping the descriptors built from
+// outdated metadata so they are recreated with the new help text
+func (bc *BaseCollector) setMetricMeta(metas []client.MetricMeta) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	changed := len(metas) != len(bc.metricMeta)
+	metricMeta := make(map[string]client.MetricMeta, len(metas))
+	for _, meta := range metas {
+		metricMeta[meta.MetricName] = meta
+		if previous, found := bc.metricMeta[meta.MetricName]; !found ||
+			previous.Description != meta.Description || previous.Unit != meta.Unit {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	bc.metricMeta = metricMeta
+	bc.metricDescs = make(map[string][]metricSeries)
+	bc.sourceMetrics = make(map[*prometheus.Desc]string)
+}
+
+// series returns the series of a metric statistic, creating them on first use
+func (bc *BaseCollector) series(metricName, statistic string) []metricSeries {
+	key := bc.descKey(metricName, statistic)
+
+	bc.mu.RLock()
+	series, exists := bc.metricDescs[key]
+	bc.mu.RUnlock()
+	if exists {
+		return series
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if series, exists := bc.metricDescs[key]; exists {
+		return series
+	}
+	series = bc.newSeries(metricName, statistic, bc.labelNames())
+	bc.metricDescs[key] = series
+	return series
+}
+
+// help returns the help text of a metric from its CMS metadata, stating the unit its values are
+// exported in. The caller must hold bc.mu.
+func (bc *BaseCollector) help(metricName, unit string) string {
+	meta, found := bc.metricMeta[metricName]
+	if !found || meta.Description == "" {
+		if unit == "" {
+			return fmt.Sprintf("%s metric from Alicloud CMS", metricName)
+		}
+		return fmt.Sprintf("%s metric from Alicloud CMS, unit %s", metricName, unit)
+	}
+	if unit == "" {
+		return fmt.Sprintf("%s (CMS %s/%s)", meta.Description, meta.Namespace, metricName)
+	}
+	return fmt.Sprintf("%s, unit %s (CMS %s/%s)", meta.Description, unit, meta.Namespace, metricName)
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/config"
+	"alicloud-exporter/internal/logger"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// GenericCollector collects metrics from any Alicloud CMS namespace described in configuration
+type GenericCollector struct {
+	*BaseCollector
+}
+
+// NewGenericCollector creates a new collector for a config-driven CMS namespace
+func NewGenericCollector(
+	client client.API,
+	config config.ServiceConfig,
+	serviceName string,
+	globalLabels map[string]string,
+	metricPrefix string,
+	log *logger.Logger,
+) *GenericCollector {
+	baseCollector := NewBaseCollector(
+		client,
+		config,
+		serviceName,
+		globalLabels,
+		metricPrefix,
+		log,
+	)
+
+	return &GenericCollector{
+		BaseCollector: baseCollector,
+	}
+}
+
+// Collect implements the ServiceCollector interface
+func (c *GenericCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	c.logger.WithService(c.serviceName).Debug("Starting metrics collection")
+
+	// Send internal metrics once collection has finished
+	defer c.CollectInternal(ch)
+
+	start := time.Now()
+	defer func() {
+		c.RecordScrapeDuration(time.Since(start))
+		c.SetLastScrapeTime(time.Now())
+	}()
+
+	// Inventory metadata is exported separately to keep CMS metric cardinality low
+	c.CollectInfo(ctx, ch)
+
+	// Use concurrent collection for better performance
+	errorCount := c.collectMetricsConcurrently(ctx, ch)
+
+	if errorCount > 0 {
+		c.RecordScrapeError()
+		return fmt.Errorf("failed to collect %d %s metrics", errorCount, c.serviceName)
+	}
+
+	return nil
+}
+
+// collectMetricsConcurrently collects metrics concurrently for better performance
+func (c *GenericCollector) collectMetricsConcurrently(ctx context.Context, ch chan<- prometheus.Metric) int {
+	metrics := c.Metrics(ctx)
+
+	var wg sync.WaitGroup
+	errorCh := make(chan error, len(metrics))
+
+	// Limit concurrent goroutines to avoid overwhelming the API
+	maxConcurrent := 10
+	semaphore := make(chan struct{}, maxConcurrent)
+
+	for _, metricName := range metrics {
+		wg.Add(1)
+		go func(metric string) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire semaphore
+			defer func() { <-semaphore }() // Release semaphore
+
+			if err := c.CollectMetric(ctx, metric, ch); err != nil {
+				errorCh <- fmt.Errorf("metric %s: %w", metric, err)
+				c.logger.WithFields(map[string]interface{}{
+					"service": c.serviceName,
+					"metric":  metric,
+				}).WithError(err).Error("Error collecting metric")
+			}
+		}(metricName)
+	}
+
+	wg.Wait()
+	close(errorCh)
+
+	// Count errors
+	errorCount := 0
+	for range errorCh {
+		errorCount++
+	}
+
+	return errorCount
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+
+	"alicloud-exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// infoLabels are the inventory labels of the info metric, followed by the mapped tags
+var infoLabels = []string{
+	"instance_id",
+	"region",
+	"name",
+	"spec",
+	"engine",
+	"engine_version",
+	"network_type",
+	"vpc_id",
+	"zone",
+	"charge_type",
+	"expire_time",
+}
+
+// instanceDescriber lists the inventory of a service
+type instanceDescriber func(ctx context.Context) ([]client.Instance, error)
+
+// initInfoDescriptor creates the info metric descriptor for services with an inventory API
+func (bc *BaseCollector) initInfoDescriptor() {
+	if bc.inventory == nil {
+		return
+	}
+
+	labels := append(append([]string{}, infoLabels...), bc.tagLabelNames()...)
+	bc.infoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(bc.metricPrefix, bc.serviceName, "info"),
+		"Inventory metadata of the instance, always 1. Join on instance_id to enrich CMS metrics.",
+		labels,
+		bc.globalLabels,
+	)
+}
+
+// CollectInfo sends an info metric for every instance in the service inventory. Regions whose
+// inventory can't be listed are logged and skipped.
+func (bc *BaseCollector) CollectInfo(ctx context.Context, ch chan<- prometheus.Metric) {
+	if bc.infoDesc == nil {
+		return
+	}
+
+	instances, err := bc.inventory(ctx)
+	if err != nil {
+		bc.logger.WithService(bc.serviceName).WithError(err).Warn("Failed to list instances, info metrics may be incomplete")
+	}
+
+	seen := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		// Instances moving between pages during listing may be returned twice
+		if seen[instance.ID] {
+			continue
+		}
+		seen[instance.ID] = true
+
+		labelValues := []string{
+			instance.ID,
+			instance.Region,
+			instance.Name,
+			instance.Spec,
+			instance.Engine,
+			instance.EngineVersion,
+			instance.NetworkType,
+			instance.VpcID,
+			instance.Zone,
+			instance.ChargeType,
+			instance.ExpireTime,
+		}
+		labelValues = append(labelValues, bc.tagLabelValues(instance.Tags)...)
+
+		metric, err := prometheus.NewConstMetric(bc.infoDesc, prometheus.GaugeValue, 1, labelValues...)
+		if err != nil {
+			bc.logger.WithField("instance_id", instance.ID).WithError(err).Error("Failed to create info metric")
+			continue
+		}
+		ch <- metric
+	}
+}
@@ -0,0 +1,180 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/config"
+	"alicloud-exporter/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lifecycleTimeLayouts are the time formats returned by the describe APIs, SLB omits the seconds
+var lifecycleTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+}
+
+// LifecycleCollector exports billing lifecycle data of SLB, RDS and Redis instances
+type LifecycleCollector struct {
+	*BaseCollector
+	describers  map[string]instanceDescriber
+	expireDesc  *prometheus.Desc
+	statusDesc  *prometheus.Desc
+	createdDesc *prometheus.Desc
+}
+
+// NewLifecycleCollector creates a new lifecycle collector
+func NewLifecycleCollector(
+	client client.API,
+	config config.ServiceConfig,
+	globalLabels map[string]string,
+	metricPrefix string,
+	log *logger.Logger,
+) *LifecycleCollector {
+	baseCollector := NewBaseCollector(
+		client,
+		config,
+		"lifecycle",
+		globalLabels,
+		metricPrefix,
+		log,
+	)
+
+	resources := config.Resources
+	if len(resources) == 0 {
+		resources = []string{"slb", "rds", "redis"}
+	}
+
+	// Share the inventory, and with it the rate limiter and tag cache, of the client
+	available := map[string]instanceDescriber{
+		"slb":   client.DescribeSLBInstances,
+		"rds":   client.DescribeRDSInstances,
+		"redis": client.DescribeRedisInstances,
+	}
+	describers := make(map[string]instanceDescriber, len(resources))
+	for _, resource := range resources {
+		if describe, found := available[resource]; found {
+			describers[resource] = describe
+		}
+	}
+
+	labels := append([]string{"resource_type", "instance_id", "region", "name"}, baseCollector.tagLabelNames()...)
+
+	return &LifecycleCollector{
+		BaseCollector: baseCollector,
+		describers:    describers,
+		expireDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricPrefix, "resource", "expire_timestamp_seconds"),
+			"Expiry time of subscription instances in seconds since the epoch.",
+			labels,
+			globalLabels,
+		),
+		statusDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricPrefix, "resource", "status"),
+			"Current status of the instance, always 1.",
+			append(append([]string{}, labels...), "status"),
+			globalLabels,
+		),
+		createdDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricPrefix, "resource", "created_timestamp_seconds"),
+			"Creation time of the instance in seconds since the epoch.",
+			labels,
+			globalLabels,
+		),
+	}
+}
+
+// Describe sends metric descriptors to the channel
+func (c *LifecycleCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.BaseCollector.Describe(ch)
+	ch <- c.expireDesc
+	ch <- c.statusDesc
+	ch <- c.createdDesc
+}
+
+// Collect implements the ServiceCollector interface
+func (c *LifecycleCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	c.logger.WithService(c.serviceName).Debug("Starting lifecycle metrics collection")
+
+	// Send internal metrics once collection has finished
+	defer c.CollectInternal(ch)
+
+	start := time.Now()
+	defer func() {
+		c.RecordScrapeDuration(time.Since(start))
+		c.SetLastScrapeTime(time.Now())
+	}()
+
+	errorCount := 0
+	for resource, describe := range c.describers {
+		instances, err := describe(ctx)
+		if err != nil {
+			// Instances of the regions that succeeded are still exported
+			errorCount++
+			c.logger.WithField("resource_type", resource).WithError(err).Error("Error listing instances")
+		}
+
+		seen := make(map[string]bool, len(instances))
+		for _, instance := range instances {
+			if seen[instance.ID] {
+				continue
+			}
+			seen[instance.ID] = true
+			c.collectInstance(ch, resource, instance)
+		}
+	}
+
+	if errorCount > 0 {
+		c.RecordScrapeError()
+		return fmt.Errorf("failed to list instances of %d resource types", errorCount)
+	}
+
+	return nil
+}
+
+// collectInstance sends the lifecycle metrics of an instance, times that are unset or can't be
+// parsed are skipped, such as the expiry time of pay-as-you-go instances
+func (c *LifecycleCollector) collectInstance(ch chan<- prometheus.Metric, resource string, instance client.Instance) {
+	labelValues := []string{resource, instance.ID, instance.Region, instance.Name}
+	labelValues = append(labelValues, c.tagLabelValues(instance.Tags)...)
+
+	if expireTime, ok := parseLifecycleTime(instance.ExpireTime); ok {
+		c.send(ch, c.expireDesc, float64(expireTime.Unix()), labelValues...)
+	}
+	if createTime, ok := parseLifecycleTime(instance.CreateTime); ok {
+		c.send(ch, c.createdDesc, float64(createTime.Unix()), labelValues...)
+	}
+	if instance.Status != "" {
+		c.send(ch, c.statusDesc, 1, append(labelValues, instance.Status)...)
+	}
+}
+
+// send creates a gauge and sends it to the channel
+func (c *LifecycleCollector) send(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labelValues ...string) {
+	metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	if err != nil {
+		c.logger.WithError(err).Error("Failed to create lifecycle metric")
+		return
+	}
+	ch <- metric
+}
+
+// parseLifecycleTime parses a time returned by the describe APIs
+func parseLifecycleTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range lifecycleTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/client/clienttest"
+	"alicloud-exporter/internal/config"
+	"alicloud-exporter/internal/logger"
+)
+
+func TestLifecycleCollector(t *testing.T) {
+	fake := clienttest.New()
+	fake.Instances["rds"] = []client.Instance{{
+		ID:         "rm-1",
+		Region:     "cn-hangzhou",
+		Name:       "orders",
+		Status:     "Running",
+		CreateTime: "2023-01-02T03:04:05Z",
+		ExpireTime: "2025-01-02T00:00:00Z",
+		Tags:       map[string]string{"Team": "db"},
+	}}
+	fake.Instances["slb"] = []client.Instance{
+		// Pay-as-you-go instances have no expiry, SLB times have no seconds
+		{ID: "lb-1", Region: "cn-hangzhou", Name: "web", Status: "active", CreateTime: "2023-01-02T03:04Z"},
+		// Times that can't be parsed are skipped
+		{ID: "lb-2", Region: "cn-hangzhou", Name: "api", Status: "inactive", CreateTime: "yesterday", ExpireTime: "soon"},
+	}
+
+	col := NewLifecycleCollector(fake, config.ServiceConfig{
+		Enabled:   true,
+		Resources: []string{"slb", "rds"},
+		TagLabels: []config.TagLabelConfig{{Tag: "Team", Label: "team"}},
+	}, nil, "alicloud", logger.New("error", "text"))
+
+	got, err := gather(t, col)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	want := map[string]float64{
+		`alicloud_resource_expire_timestamp_seconds{instance_id="rm-1",name="orders",region="cn-hangzhou",resource_type="rds",team="db"}`:  1735776000,
+		`alicloud_resource_created_timestamp_seconds{instance_id="rm-1",name="orders",region="cn-hangzhou",resource_type="rds",team="db"}`: 1672628645,
+		`alicloud_resource_status{instance_id="rm-1",name="orders",region="cn-hangzhou",resource_type="rds",status="Running",team="db"}`:   1,
+		`alicloud_resource_created_timestamp_seconds{instance_id="lb-1",name="web",region="cn-hangzhou",resource_type="slb",team=""}`:      1672628640,
+		`alicloud_resource_status{instance_id="lb-1",name="web",region="cn-hangzhou",resource_type="slb",status="active",team=""}`:         1,
+		`alicloud_resource_status{instance_id="lb-2",name="api",region="cn-hangzhou",resource_type="slb",status="inactive",team=""}`:       1,
+	}
+	compareSeries(t, got, want)
+}
+
+func TestLifecycleCollectorListingError(t *testing.T) {
+	fake := clienttest.New()
+	fake.Instances["rds"] = []client.Instance{{ID: "rm-1", Region: "cn-hangzhou", Status: "Running"}}
+	fake.Errors["DescribeSLBInstances"] = errors.New("throttled")
+
+	col := NewLifecycleCollector(fake, config.ServiceConfig{
+		Enabled:   true,
+		Resources: []string{"slb", "rds"},
+	}, nil, "alicloud", logger.New("error", "text"))
+
+	// The resources that could be listed are still exported
+	got, err := gather(t, col)
+	if err == nil {
+		t.Error("Collect() error = nil, want the SLB listing error")
+	}
+	compareSeries(t, got, map[string]float64{
+		`alicloud_resource_status{instance_id="rm-1",name="",region="cn-hangzhou",resource_type="rds",status="Running"}`: 1,
+	})
+}
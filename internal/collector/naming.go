@@ -0,0 +1,268 @@
+package collector
+
+import (
+	"strings"
+	"unicode"
+
+	"alicloud-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unitConversion converts values of a CMS unit to a Prometheus base unit
+type unitConversion struct {
+	suffix string
+	scale  float64
+}
+
+// unitConversions maps CMS unit abbreviations to base units. They are matched case-sensitively
+// since a lower-case b is a bit and an upper-case B a byte.
+var unitConversions = map[string]unitConversion{
+	"%": {"ratio", 0.01},
+
+	"ns": {"seconds", 1e-9},
+	"us": {"seconds", 1e-6},
+	"μs": {"seconds", 1e-6},
+	"ms": {"seconds", 1e-3},
+	"s":  {"seconds", 1},
+
+	"B":  {"bytes", 1},
+	"KB": {"bytes", 1 << 10},
+	"MB": {"bytes", 1 << 20},
+	"GB": {"bytes", 1 << 30},
+	"TB": {"bytes", 1 << 40},
+	"Kb": {"bytes", 1e3 / 8},
+	"Mb": {"bytes", 1e6 / 8},
+	"Gb": {"bytes", 1e9 / 8},
+
+	"bps":  {"bytes_per_second", 1.0 / 8},
+	"b/s":  {"bytes_per_second", 1.0 / 8},
+	"Kbps": {"bytes_per_second", 1e3 / 8},
+	"kbps": {"bytes_per_second", 1e3 / 8},
+	"Kb/s": {"bytes_per_second", 1e3 / 8},
+	"kb/s": {"bytes_per_second", 1e3 / 8},
+	"Mbps": {"bytes_per_second", 1e6 / 8},
+	"Mb/s": {"bytes_per_second", 1e6 / 8},
+	"Gbps": {"bytes_per_second", 1e9 / 8},
+	"Gb/s": {"bytes_per_second", 1e9 / 8},
+	"Bps":  {"bytes_per_second", 1},
+	"B/s":  {"bytes_per_second", 1},
+	"KBps": {"bytes_per_second", 1 << 10},
+	"KB/s": {"bytes_per_second", 1 << 10},
+	"MBps": {"bytes_per_second", 1 << 20},
+	"MB/s": {"bytes_per_second", 1 << 20},
+	"GBps": {"bytes_per_second", 1 << 30},
+	"GB/s": {"bytes_per_second", 1 << 30},
+
+	"/s": {"per_second", 1},
+}
+
+// spelledUnitConversions maps lower-cased CMS units spelled out in words to base units. Their
+// case carries no meaning, CMS returns both Byte/Second and bytes/s.
+var spelledUnitConversions = map[string]unitConversion{
+	"percent": {"ratio", 0.01},
+
+	"microsecond":  {"seconds", 1e-6},
+	"microseconds": {"seconds", 1e-6},
+	"millisecond":  {"seconds", 1e-3},
+	"milliseconds": {"seconds", 1e-3},
+	"sec":          {"seconds", 1},
+	"second":       {"seconds", 1},
+	"seconds":      {"seconds", 1},
+
+	"bit":    {"bytes", 1.0 / 8},
+	"bits":   {"bytes", 1.0 / 8},
+	"byte":   {"bytes", 1},
+	"bytes":  {"bytes", 1},
+	"kbyte":  {"bytes", 1 << 10},
+	"kbytes": {"bytes", 1 << 10},
+	"mbyte":  {"bytes", 1 << 20},
+	"mbytes": {"bytes", 1 << 20},
+	"gbyte":  {"bytes", 1 << 30},
+	"gbytes": {"bytes", 1 << 30},
+	"tbyte":  {"bytes", 1 << 40},
+	"tbytes": {"bytes", 1 << 40},
+
+	"bit/s":         {"bytes_per_second", 1.0 / 8},
+	"bits/s":        {"bytes_per_second", 1.0 / 8},
+	"bit/second":    {"bytes_per_second", 1.0 / 8},
+	"bits/second":   {"bytes_per_second", 1.0 / 8},
+	"kbit/s":        {"bytes_per_second", 1e3 / 8},
+	"kbits/s":       {"bytes_per_second", 1e3 / 8},
+	"kbit/second":   {"bytes_per_second", 1e3 / 8},
+	"kbits/second":  {"bytes_per_second", 1e3 / 8},
+	"mbit/s":        {"bytes_per_second", 1e6 / 8},
+	"mbits/s":       {"bytes_per_second", 1e6 / 8},
+	"mbit/second":   {"bytes_per_second", 1e6 / 8},
+	"mbits/second":  {"bytes_per_second", 1e6 / 8},
+	"gbit/s":        {"bytes_per_second", 1e9 / 8},
+	"gbits/s":       {"bytes_per_second", 1e9 / 8},
+	"byte/s":        {"bytes_per_second", 1},
+	"bytes/s":       {"bytes_per_second", 1},
+	"byte/second":   {"bytes_per_second", 1},
+	"bytes/second":  {"bytes_per_second", 1},
+	"kbyte/s":       {"bytes_per_second", 1 << 10},
+	"kbytes/s":      {"bytes_per_second", 1 << 10},
+	"kbyte/second":  {"bytes_per_second", 1 << 10},
+	"kbytes/second": {"bytes_per_second", 1 << 10},
+	"mbyte/s":       {"bytes_per_second", 1 << 20},
+	"mbytes/s":      {"bytes_per_second", 1 << 20},
+	"mbyte/second":  {"bytes_per_second", 1 << 20},
+	"mbytes/second": {"bytes_per_second", 1 << 20},
+	"gbyte/s":       {"bytes_per_second", 1 << 30},
+	"gbytes/s":      {"bytes_per_second", 1 << 30},
+
+	"count/s":          {"per_second", 1},
+	"count/second":     {"per_second", 1},
+	"frequency/s":      {"per_second", 1},
+	"frequency/second": {"per_second", 1},
+	"times/s":          {"per_second", 1},
+	"request/s":        {"per_second", 1},
+	"requests/s":       {"per_second", 1},
+	"次/秒":              {"per_second", 1},
+	"个/秒":              {"per_second", 1},
+}
+
+// lookupUnit returns the base unit conversion of a CMS unit, ignoring spaces such as in
+// "Count / Second". Abbreviations are matched case-sensitively, spelled out units are not.
+func lookupUnit(unit string) (unitConversion, bool) {
+	unit = strings.Join(strings.Fields(unit), "")
+	if conversion, found := unitConversions[unit]; found {
+		return conversion, true
+	}
+	conversion, found := spelledUnitConversions[strings.ToLower(unit)]
+	return conversion, found
+}
+
+// metricSeries is a descriptor a metric statistic is exported with and the factor its values
+// are multiplied by
+type metricSeries struct {
+	desc  *prometheus.Desc
+	scale float64
+}
+
+// idiomaticNaming reports whether idiomatic metric names are exported
+func (bc *BaseCollector) idiomaticNaming() bool {
+	return bc.config.Naming == config.NamingIdiomatic || bc.config.Naming == config.NamingMigration
+}
+
+// newSeries creates the series a metric statistic is exported as according to the naming mode,
+// the migration mode exports the idiomatic and the legacy series. The caller must hold bc.mu.
+func (bc *BaseCollector) newSeries(metricName, statistic string, labels []string) []metricSeries {
+	legacyName := bc.legacyName(metricName, statistic)
+	legacy := metricSeries{
+		desc:  bc.newDesc(legacyName, bc.help(metricName, bc.metricMeta[metricName].Unit), metricName, labels),
+		scale: 1,
+	}
+	if !bc.idiomaticNaming() {
+		return []metricSeries{legacy}
+	}
+
+	name, conversion := bc.idiomaticName(metricName, statistic)
+	unit := bc.metricUnit(metricName)
+	if conversion.suffix != "" {
+		unit = strings.ReplaceAll(conversion.suffix, "_", " ")
+	}
+	series := []metricSeries{{
+		desc:  bc.newDesc(name, bc.help(metricName, unit), metricName, labels),
+		scale: conversion.scale,
+	}}
+
+	if bc.config.Naming == config.NamingMigration && name != legacyName {
+		series = append(series, legacy)
+	}
+	return series
+}
+
+// legacyName returns the CMS metric name under the prefix and service, with the statistic suffix
+func (bc *BaseCollector) legacyName(metricName, statistic string) string {
+	name := prometheus.BuildFQName(bc.metricPrefix, bc.serviceName, metricName)
+	if statistic != "" && bc.suffixStatistics() {
+		name += "_" + config.DimensionLabelName(statistic)
+	}
+	return name
+}
+
+// idiomaticName returns the snake_case metric name with the statistic and base unit suffixes,
+// and the conversion of its values to the base unit. The caller must hold bc.mu.
+func (bc *BaseCollector) idiomaticName(metricName, statistic string) (string, unitConversion) {
+	base := snakeCase(metricName)
+	if statistic != "" && bc.suffixStatistics() {
+		base += "_" + snakeCase(statistic)
+	}
+
+	conversion, found := lookupUnit(bc.metricUnit(metricName))
+	if !found {
+		conversion = unitConversion{scale: 1}
+	}
+
+	// Names like IntranetInRatio already end with their unit
+	if conversion.suffix != "" && !strings.HasSuffix(base, "_"+conversion.suffix) {
+		base += "_" + conversion.suffix
+	}
+	return prometheus.BuildFQName(bc.metricPrefix, bc.serviceName, base), conversion
+}
+
+// metricUnit returns the configured unit of a metric, falling back to its CMS metadata.
+// Metric names are matched case-insensitively since the configuration loader lowercases map keys.
+// The caller must hold bc.mu.
+func (bc *BaseCollector) metricUnit(metricName string) string {
+	for name, unit := range bc.config.MetricUnits {
+		if strings.EqualFold(name, metricName) {
+			return unit
+		}
+	}
+	return bc.metricMeta[metricName].Unit
+}
+
+// acronyms are kept as single words by snakeCase, case changes would split MySQL into my_sql
+var acronyms = []string{"MySQL", "InnoDB", "IOPS", "QPS", "TPS", "CPU"}
+
+// snakeCase converts a CMS metric name such as MySQL_InnoDBDataRead to mysql_innodb_data_read,
+// splitting words on case changes and keeping acronyms together
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteByte('_')
+			continue
+		}
+		if acronym := acronymAt(runes, i); acronym != "" {
+			b.WriteString("_" + strings.ToLower(acronym) + "_")
+			i += len([]rune(acronym)) - 1
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	// Collapse the separators of names like MySQL_InnoDB
+	parts := strings.FieldsFunc(b.String(), func(r rune) bool { return r == '_' })
+	return strings.Join(parts, "_")
+}
+
+// acronymAt returns the acronym that is a whole word at position i of a name, such as CPU in
+// CPUUtilization but not in CPUs
+func acronymAt(runes []rune, i int) string {
+	if i > 0 && unicode.IsUpper(runes[i-1]) {
+		return ""
+	}
+	rest := string(runes[i:])
+	for _, acronym := range acronyms {
+		if !strings.HasPrefix(rest, acronym) {
+			continue
+		}
+		end := i + len([]rune(acronym))
+		if end == len(runes) || !unicode.IsLower(runes[end]) {
+			return acronym
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package collector
+
+import "testing"
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"CPUUtilization", "cpu_utilization"},
+		{"MySQL_InnoDBDataRead", "mysql_innodb_data_read"},
+		{"MySQL_QPS", "mysql_qps"},
+		{"InnoDBBufferPoolHitRate", "innodb_buffer_pool_hit_rate"},
+		{"DiskIOPSUsage", "disk_iops_usage"},
+		{"TotalTPS", "total_tps"},
+		{"HTTPSConnection", "https_connection"},
+		{"IntranetInRatio", "intranet_in_ratio"},
+		{"TrafficRXNew", "traffic_rx_new"},
+		{"Qps", "qps"},
+		{"load_5m", "load_5m"},
+		{"Instance.IOPS", "instance_iops"},
+		{"Disk__Usage_", "disk_usage"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := snakeCase(tt.name); got != tt.want {
+			t.Errorf("snakeCase(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLookupUnit(t *testing.T) {
+	tests := []struct {
+		unit      string
+		wantFound bool
+		want      unitConversion
+	}{
+		{"%", true, unitConversion{"ratio", 0.01}},
+		{"Percent", true, unitConversion{"ratio", 0.01}},
+		{"ms", true, unitConversion{"seconds", 1e-3}},
+		{"Milliseconds", true, unitConversion{"seconds", 1e-3}},
+		{" s ", true, unitConversion{"seconds", 1}},
+
+		// Bytes and bits differ only in case
+		{"Bps", true, unitConversion{"bytes_per_second", 1}},
+		{"bps", true, unitConversion{"bytes_per_second", 1.0 / 8}},
+		{"KB", true, unitConversion{"bytes", 1 << 10}},
+		{"Kb", true, unitConversion{"bytes", 1e3 / 8}},
+		{"MB/s", true, unitConversion{"bytes_per_second", 1 << 20}},
+		{"Mb/s", true, unitConversion{"bytes_per_second", 1e6 / 8}},
+		{"Kbps", true, unitConversion{"bytes_per_second", 1e3 / 8}},
+
+		// Spelled out units in the spellings CMS returns
+		{"Byte", true, unitConversion{"bytes", 1}},
+		{"KByte", true, unitConversion{"bytes", 1 << 10}},
+		{"MByte", true, unitConversion{"bytes", 1 << 20}},
+		{"GByte", true, unitConversion{"bytes", 1 << 30}},
+		{"Byte/Second", true, unitConversion{"bytes_per_second", 1}},
+		{"Bytes/s", true, unitConversion{"bytes_per_second", 1}},
+		{"KBytes/s", true, unitConversion{"bytes_per_second", 1 << 10}},
+		{"Bit/Second", true, unitConversion{"bytes_per_second", 1.0 / 8}},
+		{"bits/s", true, unitConversion{"bytes_per_second", 1.0 / 8}},
+		{"Kbits/s", true, unitConversion{"bytes_per_second", 1e3 / 8}},
+		{"Count/Second", true, unitConversion{"per_second", 1}},
+		{"Count / Second", true, unitConversion{"per_second", 1}},
+		{"Frequency/Second", true, unitConversion{"per_second", 1}},
+		{"次/秒", true, unitConversion{"per_second", 1}},
+
+		// Abbreviations in the wrong case are not guessed at
+		{"BPS", false, unitConversion{}},
+		{"kB", false, unitConversion{}},
+		{"Count", false, unitConversion{}},
+		{"", false, unitConversion{}},
+	}
+
+	for _, tt := range tests {
+		got, found := lookupUnit(tt.unit)
+		if found != tt.wantFound || got != tt.want {
+			t.Errorf("lookupUnit(%q) = %+v, %t, want %+v, %t", tt.unit, got, found, tt.want, tt.wantFound)
+		}
+	}
+}
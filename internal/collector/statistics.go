@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"alicloud-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultStatistics is the order in which a single statistic is picked when none are configured
+var defaultStatistics = []string{"Average", "Value", "Maximum", "Sum", "Minimum"}
+
+// statisticValue is a single statistic of a datapoint
+type statisticValue struct {
+	Name  string
+	Value float64
+}
+
+// statistics returns the statistics configured for a metric, falling back to the service-wide list.
+// Metric names are matched case-insensitively since the configuration loader lowercases map keys.
+func (bc *BaseCollector) statistics(metricName string) []string {
+	for name, stats := range bc.config.MetricStatistics {
+		if strings.EqualFold(name, metricName) {
+			return stats
+		}
+	}
+	return bc.config.Statistics
+}
+
+// suffixStatistics reports whether statistics are exported as metric name suffixes
+func (bc *BaseCollector) suffixStatistics() bool {
+	return bc.config.StatisticsMode == config.StatisticsModeSuffix
+}
+
+// descStatistics returns the statistics that need their own descriptor for a metric
+func (bc *BaseCollector) descStatistics(metricName string) []string {
+	stats := bc.statistics(metricName)
+	if !bc.suffixStatistics() || len(stats) == 0 {
+		return []string{""}
+	}
+	return stats
+}
+
+// descKey returns the key of the descriptor used for a metric statistic
+func (bc *BaseCollector) descKey(metricName, statistic string) string {
+	if statistic == "" || !bc.suffixStatistics() {
+		return metricName
+	}
+	return metricName + ":" + statistic
+}
+
+// newDesc creates the descriptor of a metric with the given name, help and variable labels.
+// The caller must hold bc.mu.
+func (bc *BaseCollector) newDesc(name, help, metricName string, labels []string) *prometheus.Desc {
+	if len(bc.statistics(metricName)) > 0 && !bc.suffixStatistics() {
+		labels = append(append([]string{}, labels...), "statistic")
+	}
+
+	desc := prometheus.NewDesc(
+		name,
+		help,
+		labels,
+		bc.globalLabels,
+	)
+	bc.sourceMetrics[desc] = metricName
+	return desc
+}
+
+// SourceMetric returns the CMS metric a descriptor of this collector exports
+func (bc *BaseCollector) SourceMetric(desc *prometheus.Desc) (string, bool) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	metricName, found := bc.sourceMetrics[desc]
+	return metricName, found
+}
+
+// selectStatistics returns the statistics of a datapoint to export. Without configured statistics
+// the first statistic present in the datapoint is exported, unnamed.
+func (bc *BaseCollector) selectStatistics(metricName string, data MetricData) []statisticValue {
+	stats := bc.statistics(metricName)
+	if len(stats) == 0 {
+		for _, stat := range defaultStatistics {
+			if value, found := data.Statistics[stat]; found {
+				return []statisticValue{{Value: value}}
+			}
+		}
+		return nil
+	}
+
+	values := make([]statisticValue, 0, len(stats))
+	for _, stat := range stats {
+		if value, found := data.statistic(stat); found {
+			values = append(values, statisticValue{Name: stat, Value: value})
+		}
+	}
+	return values
+}
+
+// sendStatistics sends one sample per exported statistic and series of a datapoint
+func (bc *BaseCollector) sendStatistics(ch chan<- prometheus.Metric, metricName string, data MetricData, labelValues []string) error {
+	for _, stat := range bc.selectStatistics(metricName, data) {
+		values := labelValues
+		if stat.Name != "" && !bc.suffixStatistics() {
+			values = append(append([]string{}, labelValues...), stat.Name)
+		}
+
+		for _, series := range bc.series(metricName, stat.Name) {
+			metric, err := prometheus.NewConstMetric(
+				series.desc,
+				prometheus.GaugeValue,
+				stat.Value*series.scale,
+				values...,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to create metric for %s: %w", metricName, err)
+			}
+
+			// Keep the time CMS aggregated the datapoint rather than the scrape time
+			if bc.config.Timestamps && data.Timestamp > 0 {
+				metric = prometheus.NewMetricWithTimestamp(time.UnixMilli(data.Timestamp), metric)
+			}
+
+			ch <- metric
+		}
+	}
+	return nil
+}
+
+// statistic returns a statistic of the datapoint, matching its name case-insensitively
+func (m MetricData) statistic(name string) (float64, bool) {
+	if value, found := m.Statistics[name]; found {
+		return value, true
+	}
+	for key, value := range m.Statistics {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"strings"
+
+	"alicloud-exporter/internal/config"
+)
+
+// defaultSLBTagLabels keeps the Team, Group and Name labels SLB metrics have always carried
+var defaultSLBTagLabels = []config.TagLabelConfig{
+	{Tag: "Team", Label: "Team"},
+	{Tag: "Group", Label: "Group"},
+	{Tag: "Name", Label: "Name"},
+}
+
+// tagFetcher looks up the tags of instances of a region by ID
+type tagFetcher func(ctx context.Context, region string, instanceIDs []string) (map[string]map[string]string, error)
+
+// tagLabels returns the tag to label mapping of the service
+func (bc *BaseCollector) tagLabels() []config.TagLabelConfig {
+	if len(bc.config.TagLabels) == 0 && bc.serviceName == "slb" {
+		return defaultSLBTagLabels
+	}
+	return bc.config.TagLabels
+}
+
+// tagLabelNames returns the label names of the mapped tags
+func (bc *BaseCollector) tagLabelNames() []string {
+	tagLabels := bc.tagLabels()
+	names := make([]string, 0, len(tagLabels))
+	for _, tagLabel := range tagLabels {
+		names = append(names, tagLabel.LabelName())
+	}
+	return names
+}
+
+// tagLabelValues returns the label values of the mapped tags, matching tag keys case-insensitively
+func (bc *BaseCollector) tagLabelValues(tags map[string]string) []string {
+	tagLabels := bc.tagLabels()
+	values := make([]string, 0, len(tagLabels))
+	for _, tagLabel := range tagLabels {
+		value, found := tags[tagLabel.Tag]
+		if !found {
+			for key, v := range tags {
+				if strings.EqualFold(key, tagLabel.Tag) {
+					value, found = v, true
+					break
+				}
+			}
+		}
+		if !found || value == "" {
+			value = tagLabel.Default
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// instanceTags fetches the tags of every instance in the results from the region its metrics came
+// from, continuing without tags on failure
+func (bc *BaseCollector) instanceTags(ctx context.Context, results []RegionMetricData) map[string]map[string]string {
+	if bc.fetchTags == nil || len(bc.tagLabels()) == 0 {
+		return nil
+	}
+
+	// Extract unique instance IDs per region efficiently
+	regionInstances := make(map[string]map[string]bool)
+	for _, result := range results {
+		for _, data := range result.Data {
+			if data.InstanceID == "" {
+				continue
+			}
+			if regionInstances[result.Region] == nil {
+				regionInstances[result.Region] = make(map[string]bool)
+			}
+			regionInstances[result.Region][data.InstanceID] = true
+		}
+	}
+	if len(regionInstances) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]map[string]string)
+	for region, instanceSet := range regionInstances {
+		instanceIDs := make([]string, 0, len(instanceSet))
+		for instanceID := range instanceSet {
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+
+		regionTags, err := bc.fetchTags(ctx, region, instanceIDs)
+		if err != nil {
+			bc.logger.WithService(bc.serviceName).WithField("region", region).WithError(err).Warn("Failed to get instance tags, continuing with partial tags")
+		}
+		for instanceID, instanceTags := range regionTags {
+			tags[instanceID] = instanceTags
+		}
+	}
+	return tags
+}
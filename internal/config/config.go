@@ -2,9 +2,13 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
@@ -16,23 +20,90 @@ type Config struct {
 	Alicloud   AlicloudConfig   `yaml:"alicloud" mapstructure:"alicloud"`
 	Services   ServicesConfig   `yaml:"services" mapstructure:"services"`
 	Prometheus PrometheusConfig `yaml:"prometheus" mapstructure:"prometheus"`
+	Accounts   []AccountConfig  `yaml:"accounts" mapstructure:"accounts"`
 }
 
 // ServerConfig contains server-related configuration
 type ServerConfig struct {
-	ListenAddress string `yaml:"listen_address" mapstructure:"listen_address"`
-	MetricsPath   string `yaml:"metrics_path" mapstructure:"metrics_path"`
-	LogLevel      string `yaml:"log_level" mapstructure:"log_level"`
-	LogFormat     string `yaml:"log_format" mapstructure:"log_format"`
+	ListenAddress    string `yaml:"listen_address" mapstructure:"listen_address"`
+	MetricsPath      string `yaml:"metrics_path" mapstructure:"metrics_path"`
+	LogLevel         string `yaml:"log_level" mapstructure:"log_level"`
+	LogFormat        string `yaml:"log_format" mapstructure:"log_format"`
+	BackgroundScrape bool   `yaml:"background_scrape" mapstructure:"background_scrape"` // Poll services on scrape_interval and serve snapshots
+
+	// ScrapeTimeoutOffset is subtracted from the X-Prometheus-Scrape-Timeout-Seconds header to leave
+	// time for writing the response
+	ScrapeTimeoutOffset time.Duration `yaml:"scrape_timeout_offset" mapstructure:"scrape_timeout_offset"`
 }
 
 // AlicloudConfig contains Alicloud-specific configuration
 type AlicloudConfig struct {
-	AccessKeyID     string          `yaml:"access_key_id" mapstructure:"access_key_id"`
-	AccessKeySecret string          `yaml:"access_key_secret" mapstructure:"access_key_secret"`
-	Region          string          `yaml:"region" mapstructure:"region"`
-	Regions         []string        `yaml:"regions" mapstructure:"regions"`
-	RateLimit       RateLimitConfig `yaml:"rate_limit" mapstructure:"rate_limit"`
+	AccessKeyID     string            `yaml:"access_key_id" mapstructure:"access_key_id"`
+	AccessKeySecret string            `yaml:"access_key_secret" mapstructure:"access_key_secret"`
+	Region          string            `yaml:"region" mapstructure:"region"`
+	Regions         []string          `yaml:"regions" mapstructure:"regions"`
+	RateLimit       RateLimitConfig   `yaml:"rate_limit" mapstructure:"rate_limit"`
+	Retry           RetryConfig       `yaml:"retry" mapstructure:"retry"`
+	MetricPageSize  int               `yaml:"metric_page_size" mapstructure:"metric_page_size"`
+	Credentials     CredentialsConfig `yaml:"credentials" mapstructure:"credentials"`
+	TagCache        TagCacheConfig    `yaml:"tag_cache" mapstructure:"tag_cache"`
+
+	// InventoryRefreshInterval is how often instance inventories already listed (SLB, RDS, Redis)
+	// are listed again in the background, 0 to only list them when their cache expires
+	InventoryRefreshInterval time.Duration `yaml:"inventory_refresh_interval" mapstructure:"inventory_refresh_interval"`
+
+	// Endpoints overrides the API endpoint of products (cms, slb, rds, redis) with an http or
+	// https URL, such as a private endpoint or a fake server in tests
+	Endpoints map[string]string `yaml:"endpoints" mapstructure:"endpoints"`
+
+	// RecordDir saves every API request and response as a sanitized JSON fixture in the
+	// directory, ReplayDir answers API requests from such fixtures without network access
+	RecordDir string `yaml:"record_dir" mapstructure:"record_dir"`
+	ReplayDir string `yaml:"replay_dir" mapstructure:"replay_dir"`
+}
+
+// EndpointProducts lists the products whose endpoint can be set in alicloud.endpoints
+var EndpointProducts = []string{"cms", "slb", "rds", "redis"}
+
+// AccountConfig contains the credentials of an additional Alicloud account scraped through /probe.
+// Other Alicloud settings such as rate limits and retries are shared with the alicloud section.
+type AccountConfig struct {
+	Name            string            `yaml:"name" mapstructure:"name"`
+	AccessKeyID     string            `yaml:"access_key_id" mapstructure:"access_key_id"`
+	AccessKeySecret string            `yaml:"access_key_secret" mapstructure:"access_key_secret"`
+	Region          string            `yaml:"region" mapstructure:"region"`   // Default region of probes without a region
+	Regions         []string          `yaml:"regions" mapstructure:"regions"` // Other regions probes may select, the alicloud regions by default
+	Credentials     CredentialsConfig `yaml:"credentials" mapstructure:"credentials"`
+}
+
+// CredentialsConfig selects how the exporter obtains Alicloud credentials
+type CredentialsConfig struct {
+	// Type is one of access_key, sts_token, ecs_ram_role, ram_role_arn, oidc or profile
+	Type string `yaml:"type" mapstructure:"type"`
+
+	// sts_token
+	SecurityToken string `yaml:"security_token" mapstructure:"security_token"`
+
+	// ecs_ram_role, also used as the source of ram_role_arn when no access key is configured
+	RoleName         string `yaml:"role_name" mapstructure:"role_name"`
+	MetadataEndpoint string `yaml:"metadata_endpoint" mapstructure:"metadata_endpoint"`
+
+	// ram_role_arn and oidc
+	RoleArn         string `yaml:"role_arn" mapstructure:"role_arn"`
+	RoleSessionName string `yaml:"role_session_name" mapstructure:"role_session_name"`
+	ExternalID      string `yaml:"external_id" mapstructure:"external_id"`
+	Policy          string `yaml:"policy" mapstructure:"policy"`
+	DurationSeconds int    `yaml:"duration_seconds" mapstructure:"duration_seconds"`
+	STSRegion       string `yaml:"sts_region" mapstructure:"sts_region"`
+	STSEndpoint     string `yaml:"sts_endpoint" mapstructure:"sts_endpoint"`
+
+	// oidc
+	OIDCProviderArn string `yaml:"oidc_provider_arn" mapstructure:"oidc_provider_arn"`
+	OIDCTokenFile   string `yaml:"oidc_token_file" mapstructure:"oidc_token_file"`
+
+	// profile
+	Profile string `yaml:"profile" mapstructure:"profile"`
+	File    string `yaml:"file" mapstructure:"file"`
 }
 
 // RateLimitConfig contains rate limiting configuration
@@ -41,19 +112,238 @@ type RateLimitConfig struct {
 	Burst             int `yaml:"burst" mapstructure:"burst"`
 }
 
-// ServicesConfig contains configuration for all monitored services
-type ServicesConfig struct {
-	SLB   ServiceConfig `yaml:"slb" mapstructure:"slb"`
-	Redis ServiceConfig `yaml:"redis" mapstructure:"redis"`
-	RDS   ServiceConfig `yaml:"rds" mapstructure:"rds"`
+// RetryConfig contains retry configuration for Alicloud API calls
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts" mapstructure:"max_attempts"`
+	BaseBackoff time.Duration `yaml:"base_backoff" mapstructure:"base_backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" mapstructure:"max_backoff"`
+	Jitter      float64       `yaml:"jitter" mapstructure:"jitter"`
 }
 
+// TagCacheConfig contains the settings of the cache of instance tags
+type TagCacheConfig struct {
+	TTL         time.Duration `yaml:"ttl" mapstructure:"ttl"`                   // How long tags of an instance are used
+	NegativeTTL time.Duration `yaml:"negative_ttl" mapstructure:"negative_ttl"` // How long unknown instances are cached without tags
+	StaleTTL    time.Duration `yaml:"stale_ttl" mapstructure:"stale_ttl"`       // How long expired tags are served while refreshed in the background
+	MaxEntries  int           `yaml:"max_entries" mapstructure:"max_entries"`   // Least recently used instances are evicted beyond this, 0 for no limit
+}
+
+// ServicesConfig maps service names to their configuration. The names slb, redis and rds
+// are presets with dedicated collectors, any other name is collected by the generic collector.
+type ServicesConfig map[string]ServiceConfig
+
 // ServiceConfig contains configuration for a specific service
 type ServiceConfig struct {
-	Enabled        bool          `yaml:"enabled" mapstructure:"enabled"`
-	Namespace      string        `yaml:"namespace" mapstructure:"namespace"`
-	ScrapeInterval time.Duration `yaml:"scrape_interval" mapstructure:"scrape_interval"`
-	Metrics        []string      `yaml:"metrics" mapstructure:"metrics"`
+	Enabled            bool          `yaml:"enabled" mapstructure:"enabled"`
+	Namespace          string        `yaml:"namespace" mapstructure:"namespace"`
+	ScrapeInterval     time.Duration `yaml:"scrape_interval" mapstructure:"scrape_interval"`
+	Timeout            time.Duration `yaml:"timeout" mapstructure:"timeout"` // Deadline of a collection, bounded by the scrape timeout
+	Metrics            []string      `yaml:"metrics" mapstructure:"metrics"`
+	DimensionsAsLabels []string      `yaml:"dimensions_as_labels" mapstructure:"dimensions_as_labels"`
+	Regions            []string      `yaml:"regions" mapstructure:"regions"`
+
+	// Dimensions restricts collection to the series matching any of the filters, each written as
+	// comma-separated key=value pairs such as "instanceId=lb-xxx,port=80". Strings are used because
+	// CMS dimension keys are case-sensitive and map keys would be lower-cased when loading.
+	Dimensions []string `yaml:"dimensions" mapstructure:"dimensions"`
+
+	// Statistics lists the CMS statistics (Average, Maximum, Minimum, Sum, Value, p99, ...) to export,
+	// MetricStatistics overrides it per metric and StatisticsMode selects label or suffix output
+	Statistics       []string            `yaml:"statistics" mapstructure:"statistics"`
+	MetricStatistics map[string][]string `yaml:"metric_statistics" mapstructure:"metric_statistics"`
+	StatisticsMode   string              `yaml:"statistics_mode" mapstructure:"statistics_mode"`
+
+	// Timestamps exports samples with the CMS datapoint timestamp instead of the scrape time,
+	// MaxDatapointAge drops datapoints older than the given age (0 disables the check)
+	Timestamps      bool          `yaml:"timestamps" mapstructure:"timestamps"`
+	MaxDatapointAge time.Duration `yaml:"max_datapoint_age" mapstructure:"max_datapoint_age"`
+
+	// TagLabels maps Alicloud resource tags to Prometheus labels (slb, rds, redis and lifecycle only)
+	TagLabels []TagLabelConfig `yaml:"tag_labels" mapstructure:"tag_labels"`
+
+	// Naming selects how CMS metrics are named (legacy, idiomatic or migration), defaulting to
+	// prometheus.naming. MetricUnits overrides the CMS unit of metrics for idiomatic names.
+	Naming      string            `yaml:"naming" mapstructure:"naming"`
+	MetricUnits map[string]string `yaml:"metric_units" mapstructure:"metric_units"`
+
+	// Resources lists the resource types whose lifecycle is exported (lifecycle only, defaults to all)
+	Resources []string `yaml:"resources" mapstructure:"resources"`
+}
+
+// metricSelectorChars are the characters that make a metrics entry a selector instead of a name.
+// Dots are excluded since some CMS metric names contain them.
+const metricSelectorChars = `*+?()[]{}|^$\`
+
+// MetricSelector compiles a metrics entry that selects metrics by pattern. "*" selects every
+// metric of the namespace, entries containing regular expression syntax must match the whole
+// metric name, and plain metric names return nil.
+func MetricSelector(entry string) (*regexp.Regexp, error) {
+	if entry == "*" {
+		return regexp.MustCompile(`.*`), nil
+	}
+	if !strings.ContainsAny(entry, metricSelectorChars) {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + entry + ")$")
+}
+
+// DimensionFilters parses the dimension filters of the service
+func (s ServiceConfig) DimensionFilters() ([]map[string]string, error) {
+	filters := make([]map[string]string, 0, len(s.Dimensions))
+	for _, dimension := range s.Dimensions {
+		filter := make(map[string]string)
+		for _, pair := range strings.Split(dimension, ",") {
+			key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			key = strings.TrimSpace(key)
+			if !found || key == "" {
+				return nil, fmt.Errorf("invalid dimension filter %q, expected key=value pairs", dimension)
+			}
+			filter[key] = strings.TrimSpace(value)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// TagLabelConfig maps an Alicloud tag key to a Prometheus label
+type TagLabelConfig struct {
+	Tag     string `yaml:"tag" mapstructure:"tag"`         // Tag key, matched case-insensitively
+	Label   string `yaml:"label" mapstructure:"label"`     // Label name, defaults to the sanitized tag key
+	Default string `yaml:"default" mapstructure:"default"` // Value used when the tag is missing
+}
+
+// invalidLabelChars matches characters that are not allowed in Prometheus label names
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// LabelName returns the sanitized Prometheus label name for the tag
+func (t TagLabelConfig) LabelName() string {
+	name := t.Label
+	if name == "" {
+		name = t.Tag
+	}
+
+	name = invalidLabelChars.ReplaceAllString(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// DimensionLabelName converts a CMS dimension name such as instanceId to a Prometheus label name
+func DimensionLabelName(dimension string) string {
+	var b strings.Builder
+	var prev rune
+	for i, r := range dimension {
+		switch {
+		case unicode.IsUpper(r):
+			// Only split words on a lower-to-upper transition, so "nodeIP" becomes node_ip
+			if i > 0 && (unicode.IsLower(prev) || unicode.IsDigit(prev)) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// validateDimensionLabels checks that the dimensions_as_labels of a service map to distinct valid
+// label names, which don't collide with the built-in and tag labels of its metrics
+func validateDimensionLabels(name string, service ServiceConfig) error {
+	tagLabels := make(map[string]bool, len(service.TagLabels))
+	for _, tagLabel := range service.TagLabels {
+		tagLabels[tagLabel.LabelName()] = true
+	}
+
+	dimensions := make(map[string]string, len(service.DimensionsAsLabels))
+	for _, dimension := range service.DimensionsAsLabels {
+		label := DimensionLabelName(dimension)
+		switch {
+		case label == "instance_id":
+			// Always exported as the instance_id label
+			continue
+		case !labelNamePattern.MatchString(label):
+			return fmt.Errorf("services.%s.dimensions_as_labels entry %q is not a valid label name", name, dimension)
+		case contains(reservedLabels, label):
+			return fmt.Errorf("services.%s.dimensions_as_labels entry %s conflicts with the built-in label %s", name, dimension, label)
+		case tagLabels[label]:
+			return fmt.Errorf("services.%s.dimensions_as_labels entry %s conflicts with the tag label %s", name, dimension, label)
+		}
+		if previous, found := dimensions[label]; found {
+			return fmt.Errorf("services.%s.dimensions_as_labels entries %s and %s both map to label %s", name, previous, dimension, label)
+		}
+		dimensions[label] = dimension
+	}
+	return nil
+}
+
+// TagServices lists the services whose collectors can look up resource tags
+var TagServices = []string{"slb", "rds", "redis"}
+
+// LifecycleService is the name of the service exporting expiry, status and creation time of
+// the instances of TagServices instead of CMS metrics
+const LifecycleService = "lifecycle"
+
+// reservedLabels are label names used by the collectors and info metrics that tag labels must not override
+var reservedLabels = []string{
+	"instance_id", "region", "protocol", "port", "vip", "statistic",
+	"name", "spec", "engine", "engine_version", "network_type", "vpc_id", "zone", "charge_type", "expire_time",
+	"resource_type", "status",
+}
+
+const (
+	// NamingLegacy exports CMS metric names and values unchanged
+	NamingLegacy = "legacy"
+	// NamingIdiomatic exports snake_case names with base unit suffixes and values converted to base units
+	NamingIdiomatic = "idiomatic"
+	// NamingMigration exports both the legacy and the idiomatic metrics during a transition period
+	NamingMigration = "migration"
+)
+
+const (
+	// StatisticsModeLabel exports statistics as a statistic label on a single metric
+	StatisticsModeLabel = "label"
+	// StatisticsModeSuffix exports statistics as separate metrics with a statistic suffix
+	StatisticsModeSuffix = "suffix"
+)
+
+// PresetNamespaces contains the default CMS namespace of each preset service
+var PresetNamespaces = map[string]string{
+	"slb":   "acs_slb_dashboard",
+	"redis": "acs_kvstore",
+	"rds":   "acs_rds_dashboard",
+}
+
+// serviceNamePattern matches service names usable in Prometheus metric names
+var serviceNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Names returns the configured service names in sorted order
+func (s ServicesConfig) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the configuration of a service with preset defaults applied
+func (s ServicesConfig) Get(name string) (ServiceConfig, bool) {
+	service, found := s[name]
+	if !found {
+		return ServiceConfig{}, false
+	}
+	if service.Namespace == "" {
+		service.Namespace = PresetNamespaces[name]
+	}
+	return service, true
 }
 
 // PrometheusConfig contains Prometheus-specific configuration
@@ -62,10 +352,12 @@ type PrometheusConfig struct {
 	MetricPrefix           string            `yaml:"metric_prefix" mapstructure:"metric_prefix"`
 	IncludeGoMetrics       bool              `yaml:"include_go_metrics" mapstructure:"include_go_metrics"`
 	IncludeProcessMetrics  bool              `yaml:"include_process_metrics" mapstructure:"include_process_metrics"`
+	Naming                 string            `yaml:"naming" mapstructure:"naming"`
 }
 
-// Load loads configuration from file and environment variables
-func Load(configPath string) (*Config, error) {
+// Load loads configuration from file and environment variables. Overrides, such as command
+// line flags, are applied before the configuration is validated.
+func Load(configPath string, overrides ...func(*Config)) (*Config, error) {
 	v := viper.New()
 	
 	// Set default values
@@ -91,6 +383,18 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 	
+	// Services inherit the global naming mode unless they set their own
+	for name, service := range config.Services {
+		if service.Naming == "" {
+			service.Naming = config.Prometheus.Naming
+			config.Services[name] = service
+		}
+	}
+
+	for _, override := range overrides {
+		override(&config)
+	}
+
 	// Validate configuration
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
@@ -105,28 +409,156 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.metrics_path", "/metrics")
 	v.SetDefault("server.log_level", "info")
 	v.SetDefault("server.log_format", "json")
+	v.SetDefault("server.background_scrape", false)
+	v.SetDefault("server.scrape_timeout_offset", "500ms")
 	
 	v.SetDefault("alicloud.region", "cn-hangzhou")
 	v.SetDefault("alicloud.rate_limit.requests_per_second", 10)
 	v.SetDefault("alicloud.rate_limit.burst", 20)
+	v.SetDefault("alicloud.retry.max_attempts", 3)
+	v.SetDefault("alicloud.retry.base_backoff", "200ms")
+	v.SetDefault("alicloud.retry.max_backoff", "5s")
+	v.SetDefault("alicloud.retry.jitter", 0.2)
+	v.SetDefault("alicloud.metric_page_size", 1000)
+	v.SetDefault("alicloud.tag_cache.ttl", "5m")
+	v.SetDefault("alicloud.tag_cache.negative_ttl", "1m")
+	v.SetDefault("alicloud.tag_cache.stale_ttl", "10m")
+	v.SetDefault("alicloud.tag_cache.max_entries", 10000)
+	v.SetDefault("alicloud.inventory_refresh_interval", "5m")
+	v.SetDefault("alicloud.credentials.type", "access_key")
+	v.SetDefault("alicloud.credentials.role_session_name", "alicloud-exporter")
+	v.SetDefault("alicloud.credentials.duration_seconds", 3600)
 	
 	v.SetDefault("prometheus.metric_prefix", "alicloud")
 	v.SetDefault("prometheus.include_go_metrics", false)
 	v.SetDefault("prometheus.include_process_metrics", false)
+	v.SetDefault("prometheus.naming", NamingLegacy)
 }
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
-	if c.Alicloud.AccessKeyID == "" {
-		return fmt.Errorf("alicloud.access_key_id is required")
-	}
-	if c.Alicloud.AccessKeySecret == "" {
-		return fmt.Errorf("alicloud.access_key_secret is required")
+	if err := c.Alicloud.validateCredentials(); err != nil {
+		return err
 	}
 	if c.Alicloud.Region == "" {
 		return fmt.Errorf("alicloud.region is required")
 	}
+	for product, endpoint := range c.Alicloud.Endpoints {
+		if !contains(EndpointProducts, product) {
+			return fmt.Errorf("invalid alicloud.endpoints product: %s, must be one of %v", product, EndpointProducts)
+		}
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid alicloud.endpoints.%s: %q, must be an http or https URL", product, endpoint)
+		}
+	}
+	if c.Alicloud.RecordDir != "" && c.Alicloud.ReplayDir != "" {
+		return fmt.Errorf("alicloud.record_dir and alicloud.replay_dir are mutually exclusive")
+	}
+	if c.Server.ScrapeTimeoutOffset < 0 {
+		return fmt.Errorf("server.scrape_timeout_offset must not be negative")
+	}
+	if c.Alicloud.Retry.MaxAttempts < 0 {
+		return fmt.Errorf("alicloud.retry.max_attempts must not be negative")
+	}
+	if c.Alicloud.Retry.Jitter < 0 || c.Alicloud.Retry.Jitter > 1 {
+		return fmt.Errorf("alicloud.retry.jitter must be between 0 and 1")
+	}
+	if c.Alicloud.MetricPageSize < 0 || c.Alicloud.MetricPageSize > 1440 {
+		return fmt.Errorf("alicloud.metric_page_size must be between 0 and 1440")
+	}
+	if c.Alicloud.TagCache.TTL < 0 || c.Alicloud.TagCache.NegativeTTL < 0 || c.Alicloud.TagCache.StaleTTL < 0 {
+		return fmt.Errorf("alicloud.tag_cache durations must not be negative")
+	}
+	if c.Alicloud.TagCache.MaxEntries < 0 {
+		return fmt.Errorf("alicloud.tag_cache.max_entries must not be negative")
+	}
+	if c.Alicloud.InventoryRefreshInterval < 0 {
+		return fmt.Errorf("alicloud.inventory_refresh_interval must not be negative")
+	}
 	
+	// Validate probe accounts
+	accountNames := make(map[string]bool, len(c.Accounts))
+	for _, account := range c.Accounts {
+		if account.Name == "" {
+			return fmt.Errorf("accounts entries require a name")
+		}
+		if accountNames[account.Name] {
+			return fmt.Errorf("duplicate account name: %s", account.Name)
+		}
+		accountNames[account.Name] = true
+
+		probeConfig, _ := c.ProbeConfig(account.Name, "")
+		if err := probeConfig.validateCredentials(); err != nil {
+			return fmt.Errorf("accounts.%s: %w", account.Name, err)
+		}
+	}
+
+	validNamings := []string{NamingLegacy, NamingIdiomatic, NamingMigration}
+	if c.Prometheus.Naming != "" && !contains(validNamings, c.Prometheus.Naming) {
+		return fmt.Errorf("invalid prometheus.naming: %s, must be one of %v", c.Prometheus.Naming, validNamings)
+	}
+
+	// Validate services
+	for _, name := range c.Services.Names() {
+		service, _ := c.Services.Get(name)
+		if !service.Enabled {
+			continue
+		}
+		if !serviceNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid service name: %s, must match %s", name, serviceNamePattern.String())
+		}
+		if service.Timeout < 0 {
+			return fmt.Errorf("services.%s.timeout must not be negative", name)
+		}
+		if name == LifecycleService {
+			for _, resource := range service.Resources {
+				if !contains(TagServices, resource) {
+					return fmt.Errorf("invalid services.%s.resources entry: %s, must be one of %v", name, resource, TagServices)
+				}
+			}
+		} else if service.Namespace == "" {
+			return fmt.Errorf("services.%s.namespace is required", name)
+		}
+		for _, metric := range service.Metrics {
+			if _, err := MetricSelector(metric); err != nil {
+				return fmt.Errorf("invalid services.%s.metrics selector %q: %w", name, metric, err)
+			}
+		}
+		if _, err := service.DimensionFilters(); err != nil {
+			return fmt.Errorf("services.%s.dimensions: %w", name, err)
+		}
+		if err := validateDimensionLabels(name, service); err != nil {
+			return err
+		}
+		if service.MaxDatapointAge < 0 {
+			return fmt.Errorf("services.%s.max_datapoint_age must not be negative", name)
+		}
+		if len(service.TagLabels) > 0 && !contains(TagServices, name) && name != LifecycleService {
+			return fmt.Errorf("services.%s.tag_labels is only supported for %v", name, TagServices)
+		}
+		tagLabels := make(map[string]string, len(service.TagLabels))
+		for _, tagLabel := range service.TagLabels {
+			if tagLabel.Tag == "" {
+				return fmt.Errorf("services.%s.tag_labels entries require a tag", name)
+			}
+			label := tagLabel.LabelName()
+			if contains(reservedLabels, label) {
+				return fmt.Errorf("services.%s.tag_labels label %s conflicts with a built-in label", name, label)
+			}
+			if previous, found := tagLabels[label]; found {
+				return fmt.Errorf("services.%s.tag_labels tags %s and %s both map to label %s", name, previous, tagLabel.Tag, label)
+			}
+			tagLabels[label] = tagLabel.Tag
+		}
+		if service.Naming != "" && !contains(validNamings, service.Naming) {
+			return fmt.Errorf("invalid services.%s.naming: %s, must be one of %v", name, service.Naming, validNamings)
+		}
+		validStatisticsModes := []string{"", StatisticsModeLabel, StatisticsModeSuffix}
+		if !contains(validStatisticsModes, service.StatisticsMode) {
+			return fmt.Errorf("invalid services.%s.statistics_mode: %s, must be one of %v", name, service.StatisticsMode, validStatisticsModes[1:])
+		}
+	}
+
 	// Validate log level
 	validLogLevels := []string{"debug", "info", "warn", "error"}
 	if !contains(validLogLevels, c.Server.LogLevel) {
@@ -142,6 +574,134 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// ProbeConfig returns the Alicloud configuration of a probe target. An empty account selects the
+// alicloud section, an empty region the default region of the account. The region must be one of
+// the account's regions, see ProbeRegions. The target is scraped in its region only.
+func (c *Config) ProbeConfig(account, region string) (*AlicloudConfig, error) {
+	cfg := c.Alicloud
+	if account != "" {
+		acc, found := c.account(account)
+		if !found {
+			return nil, fmt.Errorf("unknown account: %s", account)
+		}
+
+		cfg.AccessKeyID = acc.AccessKeyID
+		cfg.AccessKeySecret = acc.AccessKeySecret
+		cfg.Credentials = acc.Credentials
+		// Defaults are not applied to list entries when loading
+		if cfg.Credentials.Type == "" {
+			cfg.Credentials.Type = "access_key"
+		}
+		if cfg.Credentials.RoleSessionName == "" {
+			cfg.Credentials.RoleSessionName = c.Alicloud.Credentials.RoleSessionName
+		}
+		if cfg.Credentials.DurationSeconds == 0 {
+			cfg.Credentials.DurationSeconds = c.Alicloud.Credentials.DurationSeconds
+		}
+		if acc.Region != "" {
+			cfg.Region = acc.Region
+		}
+	}
+
+	if region != "" {
+		// Every region creates a pooled client, so probes are limited to the configured ones
+		regions, _ := c.ProbeRegions(account)
+		if !contains(regions, region) {
+			return nil, fmt.Errorf("region %s is not configured for the account, must be one of %v", region, regions)
+		}
+		cfg.Region = region
+	}
+	cfg.Regions = []string{cfg.Region}
+
+	return &cfg, nil
+}
+
+// ProbeRegions returns the sorted regions probes of an account may select: its region and regions,
+// or the region and regions of the alicloud section when the account has none
+func (c *Config) ProbeRegions(account string) ([]string, error) {
+	regions := append([]string{c.Alicloud.Region}, c.Alicloud.Regions...)
+	if account != "" {
+		acc, found := c.account(account)
+		if !found {
+			return nil, fmt.Errorf("unknown account: %s", account)
+		}
+		if len(acc.Regions) > 0 {
+			regions = append([]string{}, acc.Regions...)
+		}
+		if acc.Region != "" {
+			regions = append(regions, acc.Region)
+		}
+	}
+
+	sort.Strings(regions)
+	unique := regions[:0]
+	for _, region := range regions {
+		if region != "" && (len(unique) == 0 || unique[len(unique)-1] != region) {
+			unique = append(unique, region)
+		}
+	}
+	return unique, nil
+}
+
+// account returns the accounts entry with the given name
+func (c *Config) account(name string) (*AccountConfig, bool) {
+	for i := range c.Accounts {
+		if c.Accounts[i].Name == name {
+			return &c.Accounts[i], true
+		}
+	}
+	return nil, false
+}
+
+// validateCredentials checks that the settings required by the selected credential provider are present
+func (a *AlicloudConfig) validateCredentials() error {
+	// Replayed requests are never sent, so they need no credentials
+	if a.ReplayDir != "" {
+		return nil
+	}
+
+	creds := a.Credentials
+	switch creds.Type {
+	case "", "access_key":
+		if a.AccessKeyID == "" {
+			return fmt.Errorf("alicloud.access_key_id is required")
+		}
+		if a.AccessKeySecret == "" {
+			return fmt.Errorf("alicloud.access_key_secret is required")
+		}
+	case "sts_token":
+		if a.AccessKeyID == "" || a.AccessKeySecret == "" {
+			return fmt.Errorf("alicloud.access_key_id and alicloud.access_key_secret are required for sts_token credentials")
+		}
+		if creds.SecurityToken == "" {
+			return fmt.Errorf("alicloud.credentials.security_token is required for sts_token credentials")
+		}
+	case "ecs_ram_role", "profile":
+		// Role name and profile are discovered or defaulted at runtime
+	case "ram_role_arn":
+		if creds.RoleArn == "" {
+			return fmt.Errorf("alicloud.credentials.role_arn is required for ram_role_arn credentials")
+		}
+		if (a.AccessKeyID == "") != (a.AccessKeySecret == "") {
+			return fmt.Errorf("alicloud.access_key_id and alicloud.access_key_secret must be set together")
+		}
+	case "oidc":
+		if creds.RoleArn == "" && os.Getenv("ALIBABA_CLOUD_ROLE_ARN") == "" {
+			return fmt.Errorf("alicloud.credentials.role_arn is required for oidc credentials")
+		}
+		if creds.OIDCProviderArn == "" && os.Getenv("ALIBABA_CLOUD_OIDC_PROVIDER_ARN") == "" {
+			return fmt.Errorf("alicloud.credentials.oidc_provider_arn is required for oidc credentials")
+		}
+		if creds.OIDCTokenFile == "" && os.Getenv("ALIBABA_CLOUD_OIDC_TOKEN_FILE") == "" {
+			return fmt.Errorf("alicloud.credentials.oidc_token_file is required for oidc credentials")
+		}
+	default:
+		return fmt.Errorf("invalid credentials type: %s, must be one of %v", creds.Type,
+			[]string{"access_key", "sts_token", "ecs_ram_role", "ram_role_arn", "oidc", "profile"})
+	}
+	return nil
+}
+
 // SaveToFile saves the configuration to a YAML file
 func (c *Config) SaveToFile(filename string) error {
 	data, err := yaml.Marshal(c)
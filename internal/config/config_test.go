@@ -0,0 +1,212 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// loadConfig loads a configuration file with the given content
+func loadConfig(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestValidateLabelNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		wantErr string
+	}{
+		{
+			name: "distinct labels",
+			service: `
+    dimensions_as_labels: ["instanceId", "device", "userId"]`,
+		},
+		{
+			name: "built-in label",
+			service: `
+    dimensions_as_labels: ["region"]`,
+			wantErr: "conflicts with the built-in label region",
+		},
+		{
+			name: "statistic label",
+			service: `
+    dimensions_as_labels: ["statistic"]`,
+			wantErr: "conflicts with the built-in label statistic",
+		},
+		{
+			name: "dimensions mapping to the same label",
+			service: `
+    dimensions_as_labels: ["userId", "user_id"]`,
+			wantErr: "both map to label user_id",
+		},
+		{
+			name: "dimension matching a tag label",
+			service: `
+    dimensions_as_labels: ["team"]
+    tag_labels:
+      - tag: Team
+        label: team`,
+			wantErr: "conflicts with the tag label team",
+		},
+		{
+			name: "tags mapping to the same label",
+			service: `
+    tag_labels:
+      - tag: env
+      - tag: Environment
+        label: env`,
+			wantErr: "tags env and Environment both map to label env",
+		},
+		{
+			name: "tag label matching a lifecycle label",
+			service: `
+    tag_labels:
+      - tag: status`,
+			wantErr: "conflicts with a built-in label",
+		},
+		{
+			name: "invalid label name",
+			service: `
+    dimensions_as_labels: ["1st"]`,
+			wantErr: "not a valid label name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(t, `
+alicloud:
+  access_key_id: key
+  access_key_secret: secret
+  region: cn-hangzhou
+services:
+  rds:
+    enabled: true
+    namespace: acs_rds_dashboard`+tt.service+"\n")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProbeConfigRegions(t *testing.T) {
+	cfg, err := loadConfig(t, `
+alicloud:
+  access_key_id: key
+  access_key_secret: secret
+  region: cn-hangzhou
+  regions: ["cn-beijing", "cn-hangzhou"]
+accounts:
+  - name: prod
+    access_key_id: prod-key
+    access_key_secret: prod-secret
+    region: cn-shanghai
+  - name: staging
+    access_key_id: staging-key
+    access_key_secret: staging-secret
+    region: cn-shenzhen
+    regions: ["cn-qingdao"]
+`)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		account    string
+		region     string
+		wantRegion string
+		wantErr    string
+	}{
+		{account: "", region: "", wantRegion: "cn-hangzhou"},
+		{account: "", region: "cn-beijing", wantRegion: "cn-beijing"},
+		{account: "", region: "cn-shanghai", wantErr: "region cn-shanghai is not configured"},
+		{account: "prod", region: "", wantRegion: "cn-shanghai"},
+		{account: "prod", region: "cn-beijing", wantRegion: "cn-beijing"},
+		{account: "staging", region: "cn-qingdao", wantRegion: "cn-qingdao"},
+		{account: "staging", region: "cn-hangzhou", wantErr: "must be one of [cn-qingdao cn-shenzhen]"},
+		{account: "unknown", region: "", wantErr: "unknown account: unknown"},
+	}
+
+	for _, tt := range tests {
+		probeConfig, err := cfg.ProbeConfig(tt.account, tt.region)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ProbeConfig(%q, %q) error = %v, want %q", tt.account, tt.region, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ProbeConfig(%q, %q) error = %v", tt.account, tt.region, err)
+			continue
+		}
+		if probeConfig.Region != tt.wantRegion || len(probeConfig.Regions) != 1 || probeConfig.Regions[0] != tt.wantRegion {
+			t.Errorf("ProbeConfig(%q, %q) region = %s, regions = %v, want %s", tt.account, tt.region, probeConfig.Region, probeConfig.Regions, tt.wantRegion)
+		}
+	}
+
+	regions, err := cfg.ProbeRegions("prod")
+	if err != nil || strings.Join(regions, ",") != "cn-beijing,cn-hangzhou,cn-shanghai" {
+		t.Errorf("ProbeRegions(prod) = %v, %v, want [cn-beijing cn-hangzhou cn-shanghai]", regions, err)
+	}
+}
+
+func TestDimensionFilters(t *testing.T) {
+	tests := []struct {
+		dimensions []string
+		want       []map[string]string
+		wantErr    bool
+	}{
+		{dimensions: nil, want: []map[string]string{}},
+		{dimensions: []string{"instanceId=lb-1"}, want: []map[string]string{{"instanceId": "lb-1"}}},
+		{
+			dimensions: []string{"instanceId=lb-1, port = 80,protocol=tcp", "instanceId=lb-2"},
+			want:       []map[string]string{{"instanceId": "lb-1", "port": "80", "protocol": "tcp"}, {"instanceId": "lb-2"}},
+		},
+		{dimensions: []string{"device="}, want: []map[string]string{{"device": ""}}},
+		{dimensions: []string{"instanceId"}, wantErr: true},
+		{dimensions: []string{"=lb-1"}, wantErr: true},
+		{dimensions: []string{"instanceId=lb-1,"}, wantErr: true},
+		{dimensions: []string{"instanceId=lb-1", ""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ServiceConfig{Dimensions: tt.dimensions}.DimensionFilters()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("DimensionFilters(%q) error = %v, wantErr %t", tt.dimensions, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DimensionFilters(%q) = %v, want %v", tt.dimensions, got, tt.want)
+		}
+	}
+
+	// Malformed filters are rejected when the configuration is loaded
+	_, err := loadConfig(t, `
+alicloud:
+  access_key_id: key
+  access_key_secret: secret
+  region: cn-hangzhou
+services:
+  slb:
+    enabled: true
+    dimensions: ["instanceId"]
+`)
+	if err == nil || !strings.Contains(err.Error(), "invalid dimension filter") {
+		t.Errorf("Load() error = %v, want the invalid dimension filter", err)
+	}
+}
@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"context"
+	"time"
+
+	"alicloud-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectorResult is the outcome of a single run of a service collector
+type collectorResult struct {
+	Err      error
+	Duration time.Duration
+	TimedOut bool // The collector ran out of time and returned partial results
+	// Series counts the series collected per CMS metric
+	Series map[string]int
+}
+
+// runCollector runs a collector, forwarding its metrics to ch and counting the series of
+// each CMS metric
+func runCollector(ctx context.Context, col collector.ServiceCollector, ch chan<- prometheus.Metric) collectorResult {
+	start := time.Now()
+	resolver, _ := col.(collector.SourceMetricResolver)
+
+	series := make(map[string]int)
+	forward := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for metric := range forward {
+			if resolver != nil {
+				if metricName, found := resolver.SourceMetric(metric.Desc()); found {
+					series[metricName]++
+				}
+			}
+			ch <- metric
+		}
+	}()
+
+	err := col.Collect(ctx, forward)
+	close(forward)
+	<-done
+
+	return collectorResult{
+		Err:      err,
+		Duration: time.Since(start),
+		TimedOut: ctx.Err() == context.DeadlineExceeded,
+		Series:   series,
+	}
+}
+
+// sendCollectorResult sends the success, duration, timeout and series count metrics of a collector run
+func (e *Exporter) sendCollectorResult(ch chan<- prometheus.Metric, name string, result collectorResult) {
+	success := 1.0
+	if result.Err != nil {
+		success = 0
+	}
+	timedOut := 0.0
+	if result.TimedOut {
+		timedOut = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(e.collectorSuccessDesc, prometheus.GaugeValue, success, name)
+	ch <- prometheus.MustNewConstMetric(e.collectorDurationDesc, prometheus.GaugeValue, result.Duration.Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(e.collectorTimeoutDesc, prometheus.GaugeValue, timedOut, name)
+	for metricName, count := range result.Series {
+		ch <- prometheus.MustNewConstMetric(e.collectorMetricsDesc, prometheus.GaugeValue, float64(count), name, metricName)
+	}
+}
@@ -13,13 +13,18 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// MaxScrapeTimeout bounds live scrapes, it is the timeout of scrapes without a Prometheus
+// scrape timeout header
+const MaxScrapeTimeout = 120 * time.Second
+
 // Exporter manages all service collectors and implements prometheus.Collector
 type Exporter struct {
-	client     *client.Client
-	config     *config.Config
-	logger     *logger.Logger
-	collectors []collector.ServiceCollector
-	mu         sync.RWMutex
+	client        *client.Client
+	clientMetrics *client.Metrics
+	config        *config.Config
+	logger        *logger.Logger
+	collectors    []collector.ServiceCollector
+	mu            sync.RWMutex
 
 	// Internal metrics
 	up              prometheus.Gauge
@@ -28,20 +33,48 @@ type Exporter struct {
 	scrapeDuration  prometheus.Histogram
 	lastScrapeTime  prometheus.Gauge
 	lastScrapeError prometheus.Gauge
+	snapshotAgeDesc *prometheus.Desc
+
+	// Per-collector metrics
+	collectorSuccessDesc  *prometheus.Desc
+	collectorDurationDesc *prometheus.Desc
+	collectorMetricsDesc  *prometheus.Desc
+	collectorTimeoutDesc  *prometheus.Desc
+
+	// Configuration reload state
+	reloadSuccess   prometheus.Gauge
+	reloadTimestamp prometheus.Gauge
+	reloadMu        sync.Mutex
+	closed          bool // Set by Close, guarded by reloadMu
+
+	// Probe targets
+	probes *ClientPool
+
+	// Background scrape state
+	snapshots      *SnapshotStore
+	stopBackground context.CancelFunc
+	backgroundWg   sync.WaitGroup
 }
 
 // New creates a new Exporter with logger
 func New(cfg *config.Config, log *logger.Logger) (*Exporter, error) {
+	// Create client API usage metrics
+	clientMetrics := client.NewMetrics(cfg.Prometheus.MetricPrefix, cfg.Prometheus.GlobalLabels)
+
 	// Create Alicloud client
 	client, err := client.NewClient(&cfg.Alicloud)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Alicloud client: %w", err)
 	}
 
+	client.SetMetrics(clientMetrics)
+	client.SetLogger(log)
+
 	exporter := &Exporter{
-		client: client,
-		config: cfg,
-		logger: log,
+		client:        client,
+		clientMetrics: clientMetrics,
+		config:        cfg,
+		logger:        log,
 		up: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name:        prometheus.BuildFQName(cfg.Prometheus.MetricPrefix, "", "up"),
 			Help:        "Was the last scrape of Alicloud successful.",
@@ -73,13 +106,63 @@ func New(cfg *config.Config, log *logger.Logger) (*Exporter, error) {
 			Help:        "Whether the last scrape of Alicloud resulted in an error (1 for error, 0 for success).",
 			ConstLabels: cfg.Prometheus.GlobalLabels,
 		}),
+		snapshotAgeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(cfg.Prometheus.MetricPrefix, "", "snapshot_age_seconds"),
+			"Age of the background snapshot served for a service.",
+			[]string{"service"},
+			cfg.Prometheus.GlobalLabels,
+		),
+		reloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        prometheus.BuildFQName(cfg.Prometheus.MetricPrefix, "exporter", "config_last_reload_successful"),
+			Help:        "Whether the last configuration reload attempt was successful.",
+			ConstLabels: cfg.Prometheus.GlobalLabels,
+		}),
+		reloadTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        prometheus.BuildFQName(cfg.Prometheus.MetricPrefix, "exporter", "config_last_reload_success_timestamp_seconds"),
+			Help:        "Unix timestamp of the last successful configuration reload.",
+			ConstLabels: cfg.Prometheus.GlobalLabels,
+		}),
+		collectorSuccessDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(cfg.Prometheus.MetricPrefix, "scrape", "collector_success"),
+			"Whether a service collector succeeded.",
+			[]string{"collector"},
+			cfg.Prometheus.GlobalLabels,
+		),
+		collectorDurationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(cfg.Prometheus.MetricPrefix, "scrape", "collector_duration_seconds"),
+			"Time spent running a service collector.",
+			[]string{"collector"},
+			cfg.Prometheus.GlobalLabels,
+		),
+		collectorMetricsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(cfg.Prometheus.MetricPrefix, "scrape", "collector_metrics"),
+			"Number of series a service collector exported per CMS metric.",
+			[]string{"collector", "metric"},
+			cfg.Prometheus.GlobalLabels,
+		),
+		collectorTimeoutDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(cfg.Prometheus.MetricPrefix, "collector", "timeout"),
+			"Whether a service collector ran out of time during the last scrape (1 for timeout, 0 otherwise).",
+			[]string{"service"},
+			cfg.Prometheus.GlobalLabels,
+		),
+		snapshots: NewSnapshotStore(),
+		probes:    NewClientPool(),
 	}
 
+	// The initial load counts as a successful reload
+	exporter.recordReload(true)
+
 	// Initialize collectors
 	if err := exporter.initCollectors(); err != nil {
 		return nil, fmt.Errorf("failed to initialize collectors: %w", err)
 	}
 
+	// Start polling collectors in the background if enabled
+	if cfg.Server.BackgroundScrape {
+		exporter.startBackgroundScrape()
+	}
+
 	return exporter, nil
 }
 
@@ -90,50 +173,44 @@ func (e *Exporter) initCollectors() error {
 
 	e.collectors = make([]collector.ServiceCollector, 0)
 
-	// Initialize SLB collector
-	if e.config.Services.SLB.Enabled {
-		slbCollector := collector.NewSLBCollector(
-			e.client,
-			e.config.Services.SLB,
-			e.config.Prometheus.GlobalLabels,
-			e.config.Prometheus.MetricPrefix,
-			e.logger,
-		)
-		e.collectors = append(e.collectors, slbCollector)
+	for _, name := range e.config.Services.Names() {
+		serviceConfig, _ := e.config.Services.Get(name)
+		if !serviceConfig.Enabled {
+			continue
+		}
+		e.collectors = append(e.collectors, e.newCollector(e.config, e.client, name, serviceConfig))
 	}
 
-	// Initialize Redis collector
-	if e.config.Services.Redis.Enabled {
-		redisCollector := collector.NewRedisCollector(
-			e.client,
-			e.config.Services.Redis,
-			e.config.Prometheus.GlobalLabels,
-			e.config.Prometheus.MetricPrefix,
-			e.logger,
-		)
-		e.collectors = append(e.collectors, redisCollector)
-	}
+	return nil
+}
 
-	// Initialize RDS collector
-	if e.config.Services.RDS.Enabled {
-		rdsCollector := collector.NewRDSCollector(
-			e.client,
-			e.config.Services.RDS,
-			e.config.Prometheus.GlobalLabels,
-			e.config.Prometheus.MetricPrefix,
-			e.logger,
-		)
-		e.collectors = append(e.collectors, rdsCollector)
+// newCollector creates the collector for a service on the given client, using the preset
+// collectors for slb, redis and rds and the generic collector for any other namespace
+func (e *Exporter) newCollector(cfg *config.Config, c *client.Client, name string, serviceConfig config.ServiceConfig) collector.ServiceCollector {
+	globalLabels := cfg.Prometheus.GlobalLabels
+	metricPrefix := cfg.Prometheus.MetricPrefix
+
+	switch name {
+	case "slb":
+		return collector.NewSLBCollector(c, serviceConfig, globalLabels, metricPrefix, e.logger)
+	case "redis":
+		return collector.NewRedisCollector(c, serviceConfig, globalLabels, metricPrefix, e.logger)
+	case "rds":
+		return collector.NewRDSCollector(c, serviceConfig, globalLabels, metricPrefix, e.logger)
+	case config.LifecycleService:
+		return collector.NewLifecycleCollector(c, serviceConfig, globalLabels, metricPrefix, e.logger)
+	default:
+		return collector.NewGenericCollector(c, serviceConfig, name, globalLabels, metricPrefix, e.logger)
 	}
-
-	return nil
 }
 
 // Describe implements prometheus.Collector
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	e.describe(ch, e.GetCollectors())
+}
 
+// describe sends the descriptors of the internal metrics and the given collectors
+func (e *Exporter) describe(ch chan<- *prometheus.Desc, collectors []collector.ServiceCollector) {
 	// Send internal metrics descriptors
 	ch <- e.up.Desc()
 	ch <- e.totalScrapes.Desc()
@@ -141,38 +218,92 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.scrapeDuration.Desc()
 	ch <- e.lastScrapeTime.Desc()
 	ch <- e.lastScrapeError.Desc()
+	ch <- e.snapshotAgeDesc
+	ch <- e.collectorSuccessDesc
+	ch <- e.collectorDurationDesc
+	ch <- e.collectorMetricsDesc
+	ch <- e.collectorTimeoutDesc
+	ch <- e.reloadSuccess.Desc()
+	ch <- e.reloadTimestamp.Desc()
+	e.clientMetrics.Describe(ch)
 
 	// Send collectors descriptors
-	for _, collector := range e.collectors {
+	for _, collector := range collectors {
 		collector.Describe(ch)
 	}
 }
 
 // Collect implements prometheus.Collector
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), MaxScrapeTimeout)
+	defer cancel()
+
+	e.collect(ctx, ch, e.GetCollectors())
+}
+
+// collect runs a scrape of the given collectors bounded by ctx and sends their metrics along
+// with the internal metrics
+func (e *Exporter) collect(ctx context.Context, ch chan<- prometheus.Metric, collectors []collector.ServiceCollector) {
 	start := time.Now()
 	e.totalScrapes.Inc()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
+	var errorCount int
+	background := e.GetConfig().Server.BackgroundScrape
+	if background {
+		// Serve the latest snapshots without calling Alicloud
+		errorCount = e.collectSnapshots(ch, collectors)
+	} else {
+		errorCount = e.collectLive(ctx, ch, collectors)
+	}
 
-	// Test client health
-	if err := e.client.Health(ctx); err != nil {
-		e.up.Set(0)
-		e.scrapeErrors.Inc()
+	if errorCount > 0 {
+		// Errors of background collections are counted once, when their snapshot is stored
+		if !background {
+			e.scrapeErrors.Add(float64(errorCount))
+		}
 		e.lastScrapeError.Set(1)
-		e.logger.Error("Alicloud client health check failed", "error", err)
 	} else {
-		e.up.Set(1)
 		e.lastScrapeError.Set(0)
 	}
 
+	// Record scrape duration and time
+	duration := time.Since(start)
+	e.scrapeDuration.Observe(duration.Seconds())
+	e.lastScrapeTime.Set(float64(time.Now().Unix()))
+
+	// Send internal metrics
+	ch <- e.up
+	ch <- e.totalScrapes
+	ch <- e.scrapeErrors
+	ch <- e.scrapeDuration
+	ch <- e.lastScrapeTime
+	ch <- e.lastScrapeError
+	ch <- e.reloadSuccess
+	ch <- e.reloadTimestamp
+	e.clientMetrics.Collect(ch)
+}
+
+// collectLive checks client health and collects from the given collectors that are enabled,
+// returning the number of failed collectors. Collectors running out of time keep the metrics
+// sent so far and are reported by the collector timeout metric.
+func (e *Exporter) collectLive(ctx context.Context, ch chan<- prometheus.Metric, collectors []collector.ServiceCollector) int {
+	cfg := e.GetConfig()
+
+	// Check the client current at the start of the scrape, a reload swaps it without waiting
+	// for the scrape to finish
+	e.mu.RLock()
+	client := e.client
+	e.mu.RUnlock()
+
+	// Test client health
+	e.recordHealth(client.Health(ctx))
+
 	// Collect from all enabled collectors
 	errorCount := 0
 	var wg sync.WaitGroup
-	errorCh := make(chan error, len(e.collectors))
+	errorCh := make(chan error, len(collectors))
 
-	for _, col := range e.collectors {
+	for _, col := range collectors {
 		if !col.Enabled() {
 			continue
 		}
@@ -180,8 +311,18 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		wg.Add(1)
 		go func(c collector.ServiceCollector) {
 			defer wg.Done()
-			if err := c.Collect(ctx, ch); err != nil {
-				errorCh <- fmt.Errorf("collector %s failed: %w", c.Name(), err)
+
+			collectCtx, cancel := collectorContext(ctx, cfg.Services[c.Name()].Timeout)
+			defer cancel()
+
+			result := runCollector(collectCtx, c, ch)
+			e.sendCollectorResult(ch, c.Name(), result)
+			if result.TimedOut {
+				e.logger.WithService(c.Name()).Warn("Collector ran out of time, returning partial results")
+			}
+
+			if result.Err != nil {
+				errorCh <- fmt.Errorf("collector %s failed: %w", c.Name(), result.Err)
 			}
 		}(col)
 	}
@@ -195,35 +336,47 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		e.logger.Error("Collection error", "error", err)
 	}
 
-	if errorCount > 0 {
-		e.scrapeErrors.Add(float64(errorCount))
+	return errorCount
+}
+
+// collectorContext bounds the context of a scrape by the timeout of a service, if set
+func collectorContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// recordHealth updates the up metric from the result of a client health check
+func (e *Exporter) recordHealth(err error) {
+	if err != nil {
+		e.up.Set(0)
+		e.scrapeErrors.Inc()
 		e.lastScrapeError.Set(1)
+		e.logger.Error("Alicloud client health check failed", "error", err)
 	} else {
+		e.up.Set(1)
 		e.lastScrapeError.Set(0)
 	}
-
-	// Record scrape duration and time
-	duration := time.Since(start)
-	e.scrapeDuration.Observe(duration.Seconds())
-	e.lastScrapeTime.Set(float64(time.Now().Unix()))
-
-	// Send internal metrics
-	ch <- e.up
-	ch <- e.totalScrapes
-	ch <- e.scrapeErrors
-	ch <- e.scrapeDuration
-	ch <- e.lastScrapeTime
-	ch <- e.lastScrapeError
 }
 
 // Close closes the exporter and releases resources
 func (e *Exporter) Close() error {
+	// Wait for a running reload, which stops and restarts the pollers, and refuse later ones
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+	e.closed = true
+
+	// Stop background polling before taking the lock, pollers read collectors
+	e.stopBackgroundScrape()
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	if e.client != nil {
 		e.client.Close()
 	}
+	e.probes.Close()
 
 	return nil
 }
@@ -240,5 +393,7 @@ func (e *Exporter) GetCollectors() []collector.ServiceCollector {
 
 // GetConfig returns the exporter configuration
 func (e *Exporter) GetConfig() *config.Config {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 	return e.config
 }
@@ -0,0 +1,140 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"alicloud-exporter/internal/client/clienttest"
+	"alicloud-exporter/internal/collector"
+	"alicloud-exporter/internal/config"
+	"alicloud-exporter/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testConfig returns a configuration pointing the Alicloud client at server, with the given services
+func testConfig(server string, services config.ServicesConfig) *config.Config {
+	return &config.Config{
+		Server: config.ServerConfig{
+			LogLevel:            "error",
+			LogFormat:           "text",
+			ScrapeTimeoutOffset: 500 * time.Millisecond,
+		},
+		Alicloud: config.AlicloudConfig{
+			AccessKeyID:     "test-key",
+			AccessKeySecret: "test-secret",
+			Region:          "cn-hangzhou",
+			RateLimit:       config.RateLimitConfig{RequestsPerSecond: 1000, Burst: 1000},
+			Retry:           config.RetryConfig{MaxAttempts: 1},
+			Credentials:     config.CredentialsConfig{Type: "access_key"},
+			Endpoints:       map[string]string{"cms": server, "slb": server, "rds": server},
+		},
+		Services:   services,
+		Prometheus: config.PrometheusConfig{MetricPrefix: "alicloud"},
+	}
+}
+
+// newTestExporter creates an exporter on a fake Alicloud server, applying configure to its
+// configuration first
+func newTestExporter(t *testing.T, fake *clienttest.Fake, configure func(*config.Config)) *Exporter {
+	t.Helper()
+
+	server := clienttest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := testConfig(server.URL, config.ServicesConfig{})
+	if configure != nil {
+		configure(cfg)
+	}
+
+	e, err := New(cfg, logger.Discard())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+// stubCollector is a service collector sending a single gauge per collection and failing with
+// err. With block set it waits for its context to end before returning.
+type stubCollector struct {
+	name  string
+	err   error
+	block bool
+	desc  *prometheus.Desc
+	calls atomic.Int32
+}
+
+func newStubCollector(name string, err error) *stubCollector {
+	return &stubCollector{
+		name: name,
+		err:  err,
+		desc: prometheus.NewDesc("alicloud_"+name+"_stub", "Stub metric.", nil, nil),
+	}
+}
+
+func (s *stubCollector) Describe(ch chan<- *prometheus.Desc) { ch <- s.desc }
+
+func (s *stubCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	s.calls.Add(1)
+	ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, 1)
+	if s.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return s.err
+}
+
+func (s *stubCollector) Name() string { return s.name }
+
+func (s *stubCollector) Enabled() bool { return true }
+
+// gather scrapes the given collectors of the exporter once and returns the gauge and counter
+// series as name{label="value",...} with their values
+func gather(t *testing.T, e *Exporter, ctx context.Context, collectors []collector.ServiceCollector) map[string]float64 {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collectorSet{exporter: e, collectors: collectors, ctx: ctx})
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	series := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+			}
+			sort.Strings(labels)
+			name := family.GetName() + "{" + strings.Join(labels, ",") + "}"
+
+			switch {
+			case metric.GetGauge() != nil:
+				series[name] = metric.GetGauge().GetValue()
+			case metric.GetCounter() != nil:
+				series[name] = metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return series
+}
+
+// waitFor polls condition until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,114 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alicloud-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scrapeTimeoutHeader is the header Prometheus sends with the scrape timeout of the target
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// collectorSet scrapes collectors of the exporter within the deadline of a request, along
+// with the internal metrics
+type collectorSet struct {
+	exporter   *Exporter
+	collectors []collector.ServiceCollector
+	ctx        context.Context
+}
+
+// Describe implements prometheus.Collector
+func (s *collectorSet) Describe(ch chan<- *prometheus.Desc) {
+	s.exporter.describe(ch, s.collectors)
+}
+
+// Collect implements prometheus.Collector
+func (s *collectorSet) Collect(ch chan<- prometheus.Metric) {
+	s.exporter.collect(s.ctx, ch, s.collectors)
+}
+
+// MetricsHandler serves the metrics endpoint: the exporter's metrics along with those of
+// gatherer, such as Go and process metrics. Requests with collect[] parameters, such as
+// ?collect[]=slb&collect[]=rds, scrape only the named collectors, answering 400 for unknown
+// names. Scrapes end before the Prometheus scrape timeout, see scrapeContext.
+func (e *Exporter) MetricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	opts := promhttp.HandlerOpts{
+		ErrorLog:      e.logger.Logger,
+		ErrorHandling: promhttp.ContinueOnError,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collectors := e.GetCollectors()
+		if names := r.URL.Query()["collect[]"]; len(names) > 0 {
+			var err error
+			if collectors, err = e.selectCollectors(names); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := e.scrapeContext(r)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(&collectorSet{exporter: e, collectors: collectors, ctx: ctx}); err != nil {
+			http.Error(w, fmt.Sprintf("failed to register collectors: %v", err), http.StatusInternalServerError)
+			return
+		}
+		promhttp.HandlerFor(prometheus.Gatherers{gatherer, registry}, opts).ServeHTTP(w, r)
+	})
+}
+
+// scrapeContext returns the context of a scrape request. Its deadline is the Prometheus scrape
+// timeout minus server.scrape_timeout_offset, capped at MaxScrapeTimeout.
+func (e *Exporter) scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	timeout := MaxScrapeTimeout
+	if header := r.Header.Get(scrapeTimeoutHeader); header != "" {
+		seconds, err := strconv.ParseFloat(header, 64)
+		if err != nil || seconds <= 0 {
+			e.logger.WithField("header", header).Warn("Invalid scrape timeout header, using the default timeout")
+		} else {
+			scrapeTimeout := time.Duration(seconds * float64(time.Second))
+			// Keep the full scrape timeout when the offset would use it up
+			if offset := e.GetConfig().Server.ScrapeTimeoutOffset; offset < scrapeTimeout {
+				scrapeTimeout -= offset
+			}
+			if scrapeTimeout < timeout {
+				timeout = scrapeTimeout
+			}
+		}
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// selectCollectors returns the active collectors with the given names
+func (e *Exporter) selectCollectors(names []string) ([]collector.ServiceCollector, error) {
+	active := e.GetCollectors()
+	byName := make(map[string]collector.ServiceCollector, len(active))
+	valid := make([]string, 0, len(active))
+	for _, col := range active {
+		byName[col.Name()] = col
+		valid = append(valid, col.Name())
+	}
+
+	selected := make([]collector.ServiceCollector, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		col, found := byName[name]
+		if !found {
+			return nil, fmt.Errorf("unknown collector: %s, valid collectors: %s", name, strings.Join(valid, ", "))
+		}
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, col)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,148 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"alicloud-exporter/internal/client/clienttest"
+	"alicloud-exporter/internal/collector"
+	"alicloud-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsHandlerCollectors(t *testing.T) {
+	e := newTestExporter(t, clienttest.New(), nil)
+	e.collectors = []collector.ServiceCollector{
+		newStubCollector("slb", nil),
+		newStubCollector("rds", nil),
+		newStubCollector("redis", nil),
+	}
+	server := httptest.NewServer(e.MetricsHandler(prometheus.NewRegistry()))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		want       []string
+		skip       []string
+	}{
+		{
+			name:       "all collectors",
+			wantStatus: http.StatusOK,
+			want:       []string{"alicloud_slb_stub 1", "alicloud_rds_stub 1", "alicloud_redis_stub 1", "alicloud_up 1"},
+		},
+		{
+			name:       "selected collectors",
+			query:      "collect[]=slb&collect[]=rds&collect[]=slb",
+			wantStatus: http.StatusOK,
+			want: []string{
+				"alicloud_slb_stub 1",
+				"alicloud_rds_stub 1",
+				`alicloud_scrape_collector_success{collector="slb"} 1`,
+				`alicloud_scrape_collector_success{collector="rds"} 1`,
+				"alicloud_up 1",
+			},
+			skip: []string{"alicloud_redis_stub", `collector="redis"`},
+		},
+		{
+			name:       "unknown collector",
+			query:      "collect[]=slb&collect[]=ecs",
+			wantStatus: http.StatusBadRequest,
+			want:       []string{"unknown collector: ecs, valid collectors: slb, rds, redis"},
+			skip:       []string{"alicloud_slb_stub"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + "?" + tt.query)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+			for _, skip := range tt.skip {
+				if strings.Contains(string(body), skip) {
+					t.Errorf("body contains %q:\n%s", skip, body)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectLiveTimeout(t *testing.T) {
+	e := newTestExporter(t, clienttest.New(), func(cfg *config.Config) {
+		cfg.Services = config.ServicesConfig{"slow": {Timeout: 20 * time.Millisecond}}
+	})
+	slow := newStubCollector("slow", nil)
+	slow.block = true
+	fast := newStubCollector("fast", nil)
+
+	// The slow collector keeps the metric sent before its timeout
+	series := gather(t, e, context.Background(), []collector.ServiceCollector{slow, fast})
+	want := map[string]float64{
+		`alicloud_slow_stub{}`:                                1,
+		`alicloud_collector_timeout{service="slow"}`:          1,
+		`alicloud_collector_timeout{service="fast"}`:          0,
+		`alicloud_scrape_collector_success{collector="fast"}`: 1,
+	}
+	for name, value := range want {
+		if got, found := series[name]; !found || got != value {
+			t.Errorf("series %s = %v, %t, want %v", name, got, found, value)
+		}
+	}
+}
+
+func TestScrapeContext(t *testing.T) {
+	e := newTestExporter(t, clienttest.New(), nil)
+
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "no header", want: MaxScrapeTimeout},
+		{name: "offset subtracted", header: "10", want: 9500 * time.Millisecond},
+		{name: "fractional seconds", header: "2.5", want: 2 * time.Second},
+		{name: "timeout within the offset", header: "0.4", want: 400 * time.Millisecond},
+		{name: "capped", header: "300", want: MaxScrapeTimeout},
+		{name: "malformed", header: "10s", want: MaxScrapeTimeout},
+		{name: "negative", header: "-5", want: MaxScrapeTimeout},
+		{name: "zero", header: "0", want: MaxScrapeTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				r.Header.Set(scrapeTimeoutHeader, tt.header)
+			}
+
+			ctx, cancel := e.scrapeContext(r)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				t.Fatal("scrape context has no deadline")
+			}
+			if remaining := time.Until(deadline); remaining > tt.want || remaining < tt.want-100*time.Millisecond {
+				t.Errorf("scrape timeout = %v, want %v", remaining, tt.want)
+			}
+		})
+	}
+}
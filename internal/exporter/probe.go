@@ -0,0 +1,304 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/collector"
+	"alicloud-exporter/internal/config"
+	"alicloud-exporter/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// maxProbeCollectors bounds the collectors kept per probe target. Instance probes create a
+// collector per service and instance, the least recently used are dropped beyond the bound.
+const maxProbeCollectors = 1000
+
+// ProbeTarget is the client of an account and region with the collectors created for it
+type ProbeTarget struct {
+	Client     *client.Client
+	collectors map[string]*probeTargetCollector
+	mu         sync.Mutex
+}
+
+// probeTargetCollector is a collector of a probe target and when a probe last used it
+type probeTargetCollector struct {
+	collector collector.ServiceCollector
+	lastUsed  time.Time
+}
+
+// ClientPool reuses the clients and collectors of probe targets across probes
+type ClientPool struct {
+	targets map[string]*ProbeTarget
+	mu      sync.Mutex
+}
+
+// NewClientPool creates an empty client pool
+func NewClientPool() *ClientPool {
+	return &ClientPool{
+		targets: make(map[string]*ProbeTarget),
+	}
+}
+
+// Get returns the target of an account and region, creating its client with newClient on first use
+func (p *ClientPool) Get(account, region string, newClient func() (*client.Client, error)) (*ProbeTarget, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := account + "/" + region
+	if target, found := p.targets[key]; found {
+		return target, nil
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	target := &ProbeTarget{
+		Client:     c,
+		collectors: make(map[string]*probeTargetCollector),
+	}
+	p.targets[key] = target
+	return target, nil
+}
+
+// Close closes the clients of all targets
+func (p *ClientPool) Close() {
+	for _, c := range p.drain() {
+		c.Close()
+	}
+}
+
+// drain removes all targets and returns their clients, which the caller must close
+func (p *ClientPool) drain() []*client.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clients := make([]*client.Client, 0, len(p.targets))
+	for key, target := range p.targets {
+		clients = append(clients, target.Client)
+		delete(p.targets, key)
+	}
+	return clients
+}
+
+// resetCollectors drops the collectors of all targets so they are recreated from the current
+// service configuration, keeping the clients
+func (p *ClientPool) resetCollectors() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, target := range p.targets {
+		target.mu.Lock()
+		target.collectors = make(map[string]*probeTargetCollector)
+		target.mu.Unlock()
+	}
+}
+
+// collector returns the collector of a service for the target, creating it on first use and
+// dropping the least recently used collector when the target has maxProbeCollectors
+func (t *ProbeTarget) collector(name string, newCollector func() collector.ServiceCollector) collector.ServiceCollector {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if entry, found := t.collectors[name]; found {
+		entry.lastUsed = now
+		return entry.collector
+	}
+
+	if len(t.collectors) >= maxProbeCollectors {
+		var oldest string
+		for key, entry := range t.collectors {
+			if oldest == "" || entry.lastUsed.Before(t.collectors[oldest].lastUsed) {
+				oldest = key
+			}
+		}
+		delete(t.collectors, oldest)
+	}
+
+	col := newCollector()
+	t.collectors[name] = &probeTargetCollector{collector: col, lastUsed: now}
+	return col
+}
+
+// probeCollector runs the collectors of a single probe
+type probeCollector struct {
+	collectors   []collector.ServiceCollector
+	ctx          context.Context
+	logger       *logger.Logger
+	successDesc  *prometheus.Desc
+	durationDesc *prometheus.Desc
+}
+
+// Describe implements prometheus.Collector. Probe metrics are unchecked since the collected
+// services vary per probe.
+func (p *probeCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (p *probeCollector) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	errorCh := make(chan error, len(p.collectors))
+	for _, col := range p.collectors {
+		wg.Add(1)
+		go func(c collector.ServiceCollector) {
+			defer wg.Done()
+			if err := c.Collect(p.ctx, ch); err != nil {
+				errorCh <- fmt.Errorf("collector %s failed: %w", c.Name(), err)
+			}
+		}(col)
+	}
+	wg.Wait()
+	close(errorCh)
+
+	success := 1.0
+	for err := range errorCh {
+		success = 0
+		p.logger.WithError(err).Error("Probe collection error")
+	}
+
+	ch <- prometheus.MustNewConstMetric(p.successDesc, prometheus.GaugeValue, success)
+	ch <- prometheus.MustNewConstMetric(p.durationDesc, prometheus.GaugeValue, time.Since(start).Seconds())
+}
+
+// ProbeHandler serves /probe?account=<name>&region=<region>&service=<name>&instance=<id>, collecting
+// the requested services (all enabled services by default) with the credentials of an account
+// from the accounts section. Without an account the alicloud section is used. An instance restricts
+// collection to the metrics of a single instance, as listed by /sd/targets.
+func (e *Exporter) ProbeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		cfg := e.GetConfig()
+
+		services, err := probeServices(cfg, query["service"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		instance := query.Get("instance")
+		if strings.ContainsAny(instance, ",=") {
+			http.Error(w, fmt.Sprintf("invalid instance: %s", instance), http.StatusBadRequest)
+			return
+		}
+
+		target, status, err := e.probeTarget(cfg, query.Get("account"), query.Get("region"))
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		collectors := make([]collector.ServiceCollector, 0, len(services))
+		for _, name := range services {
+			serviceConfig, _ := cfg.Services.Get(name)
+			// The probe selects the services and the region to collect
+			serviceConfig.Enabled = true
+			serviceConfig.Regions = nil
+			if instance != "" {
+				serviceConfig.Dimensions = []string{"instanceId=" + instance}
+			}
+			collectors = append(collectors, target.collector(name+"/"+instance, func() collector.ServiceCollector {
+				return e.newCollector(cfg, target.Client, name, serviceConfig)
+			}))
+		}
+
+		ctx, cancel := e.scrapeContext(r)
+		defer cancel()
+
+		prefix := cfg.Prometheus.MetricPrefix
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(&probeCollector{
+			collectors: collectors,
+			ctx:        ctx,
+			logger:     e.logger,
+			successDesc: prometheus.NewDesc(
+				prometheus.BuildFQName(prefix, "probe", "success"),
+				"Whether all collectors of the probe succeeded.",
+				nil,
+				cfg.Prometheus.GlobalLabels,
+			),
+			durationDesc: prometheus.NewDesc(
+				prometheus.BuildFQName(prefix, "probe", "duration_seconds"),
+				"Time spent collecting the probe.",
+				nil,
+				cfg.Prometheus.GlobalLabels,
+			),
+		})
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+			ErrorLog:      e.logger.Logger,
+			ErrorHandling: promhttp.ContinueOnError,
+		}).ServeHTTP(w, r)
+	})
+}
+
+// probeTarget returns the pooled target of an account and region, along with the HTTP status to
+// answer with when it can't be created
+func (e *Exporter) probeTarget(cfg *config.Config, account, region string) (*ProbeTarget, int, error) {
+	probeConfig, err := cfg.ProbeConfig(account, region)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	target, err := e.probes.Get(account, probeConfig.Region, func() (*client.Client, error) {
+		c, err := client.NewClient(probeConfig)
+		if err != nil {
+			return nil, err
+		}
+		c.SetMetrics(e.clientMetrics)
+		c.SetLogger(e.logger)
+		return c, nil
+	})
+	if err != nil {
+		e.logger.WithField("account", account).WithError(err).Error("Failed to create probe client")
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	return target, http.StatusOK, nil
+}
+
+// probeServices resolves the services requested by a probe, accepting repeated and
+// comma-separated service parameters. No services select every enabled service.
+func probeServices(cfg *config.Config, params []string) ([]string, error) {
+	var services []string
+	for _, param := range params {
+		for _, name := range strings.Split(param, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				services = append(services, name)
+			}
+		}
+	}
+
+	if len(services) == 0 {
+		for _, name := range cfg.Services.Names() {
+			if service, _ := cfg.Services.Get(name); service.Enabled {
+				services = append(services, name)
+			}
+		}
+		return services, nil
+	}
+
+	seen := make(map[string]bool, len(services))
+	unique := services[:0]
+	for _, name := range services {
+		if _, found := cfg.Services[name]; !found {
+			return nil, fmt.Errorf("unknown service: %s, must be one of %v", name, cfg.Services.Names())
+		}
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	sort.Strings(unique)
+	return unique, nil
+}
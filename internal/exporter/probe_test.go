@@ -0,0 +1,143 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alicloud-exporter/internal/client/clienttest"
+	"alicloud-exporter/internal/collector"
+	"alicloud-exporter/internal/config"
+)
+
+func TestProbeHandler(t *testing.T) {
+	fake := clienttest.New("cn-hangzhou", "cn-beijing")
+	fake.AddDatapoints("cn-beijing", "acs_ecs_dashboard", "CPUUtilization",
+		clienttest.Datapoint{"instanceId": "i-1", "Average": 40.0},
+		clienttest.Datapoint{"instanceId": "i-2", "Average": 60.0},
+	)
+	e := newTestExporter(t, fake, func(cfg *config.Config) {
+		cfg.Alicloud.Regions = []string{"cn-hangzhou", "cn-beijing"}
+		cfg.Services = config.ServicesConfig{
+			"ecs": {Enabled: true, Namespace: "acs_ecs_dashboard", Metrics: []string{"CPUUtilization"}},
+		}
+	})
+	server := httptest.NewServer(e.ProbeHandler())
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   []string
+		skipBody   []string
+	}{
+		{
+			name:       "configured region",
+			query:      "region=cn-beijing",
+			wantStatus: http.StatusOK,
+			wantBody: []string{
+				`alicloud_ecs_CPUUtilization{instance_id="i-1",region="cn-beijing"} 40`,
+				`alicloud_ecs_CPUUtilization{instance_id="i-2",region="cn-beijing"} 60`,
+				"alicloud_probe_success 1",
+			},
+		},
+		{
+			name:       "single instance",
+			query:      "region=cn-beijing&service=ecs&instance=i-2",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`alicloud_ecs_CPUUtilization{instance_id="i-2",region="cn-beijing"} 60`},
+			skipBody:   []string{`instance_id="i-1"`},
+		},
+		{
+			name:       "unconfigured region",
+			query:      "region=cn-shanghai",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"region cn-shanghai is not configured"},
+		},
+		{
+			name:       "unknown account",
+			query:      "account=unknown",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"unknown account: unknown"},
+		},
+		{
+			name:       "unknown service",
+			query:      "service=ecs,nat",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"unknown service: nat"},
+		},
+		{
+			name:       "invalid instance",
+			query:      "instance=i-1,i-2",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"invalid instance"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + "?" + tt.query)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+			for _, skip := range tt.skipBody {
+				if strings.Contains(string(body), skip) {
+					t.Errorf("body contains %q:\n%s", skip, body)
+				}
+			}
+		})
+	}
+
+	// Rejected regions create no client
+	e.probes.mu.Lock()
+	defer e.probes.mu.Unlock()
+	if _, found := e.probes.targets["/cn-shanghai"]; found || len(e.probes.targets) != 1 {
+		t.Errorf("pooled targets = %v, want cn-beijing only", e.probes.targets)
+	}
+}
+
+func TestProbeTargetEvictsLeastRecentlyUsedCollector(t *testing.T) {
+	target := &ProbeTarget{collectors: make(map[string]*probeTargetCollector)}
+	created := 0
+	get := func(name string) collector.ServiceCollector {
+		return target.collector(name, func() collector.ServiceCollector {
+			created++
+			return newStubCollector("ecs", nil)
+		})
+	}
+
+	for i := 0; i < maxProbeCollectors; i++ {
+		get(fmt.Sprintf("ecs/i-%d", i))
+	}
+	first := get("ecs/i-0")
+	if created != maxProbeCollectors {
+		t.Fatalf("created %d collectors, want %d", created, maxProbeCollectors)
+	}
+
+	// The next collector evicts i-1, which was used least recently
+	get("ecs/i-new")
+	if len(target.collectors) != maxProbeCollectors {
+		t.Errorf("target has %d collectors, want %d", len(target.collectors), maxProbeCollectors)
+	}
+	if _, found := target.collectors["ecs/i-1"]; found {
+		t.Error("least recently used collector ecs/i-1 was kept")
+	}
+	if get("ecs/i-0") != first {
+		t.Error("recently used collector ecs/i-0 was evicted")
+	}
+}
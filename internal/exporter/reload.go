@@ -0,0 +1,161 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/collector"
+	"alicloud-exporter/internal/config"
+)
+
+// Reload loads a new configuration with load and applies it without restarting: only the
+// collectors of changed services are rebuilt, and the client is replaced when the alicloud
+// section changes. The server and prometheus sections require a restart and are kept as is.
+func (e *Exporter) Reload(load func() (*config.Config, error)) error {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	if e.closed {
+		return fmt.Errorf("exporter is closed")
+	}
+
+	if err := e.reload(load); err != nil {
+		e.recordReload(false)
+		e.logger.WithError(err).Error("Failed to reload configuration")
+		return err
+	}
+
+	e.recordReload(true)
+	e.logger.Info("Configuration reloaded")
+	return nil
+}
+
+// reload applies a new configuration, leaving the current one in place on error
+func (e *Exporter) reload(load func() (*config.Config, error)) error {
+	cfg, err := load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	old := e.GetConfig()
+	if !reflect.DeepEqual(old.Server, cfg.Server) {
+		e.logger.Warn("Changes to the server section require a restart and were not applied")
+	}
+	if !reflect.DeepEqual(old.Prometheus, cfg.Prometheus) {
+		e.logger.Warn("Changes to the prometheus section require a restart and were not applied")
+	}
+	cfg.Server = old.Server
+	cfg.Prometheus = old.Prometheus
+
+	// Create the new client before touching the running state so a failure keeps the old one
+	newClient := e.client
+	clientChanged := !reflect.DeepEqual(old.Alicloud, cfg.Alicloud)
+	if clientChanged {
+		newClient, err = client.NewClient(&cfg.Alicloud)
+		if err != nil {
+			return fmt.Errorf("failed to create Alicloud client: %w", err)
+		}
+		newClient.SetMetrics(e.clientMetrics)
+		newClient.SetLogger(e.logger)
+	}
+
+	// Pollers read the collectors and the client, stop them for the swap
+	e.stopBackgroundScrape()
+
+	e.mu.Lock()
+	oldClient := e.client
+	e.collectors = e.reloadCollectors(old, cfg, newClient, clientChanged)
+	e.client = newClient
+	e.config = cfg
+
+	// Probe clients are closed after unlocking, like the old client
+	var oldProbeClients []*client.Client
+	switch {
+	case clientChanged || !reflect.DeepEqual(old.Accounts, cfg.Accounts):
+		oldProbeClients = e.probes.drain()
+	case !reflect.DeepEqual(old.Services, cfg.Services):
+		e.probes.resetCollectors()
+	}
+
+	if cfg.Server.BackgroundScrape {
+		e.startBackgroundScrape()
+	}
+	e.mu.Unlock()
+
+	if clientChanged {
+		e.logger.Info("Alicloud configuration changed, replaced client")
+		oldClient.Close()
+	}
+	for _, c := range oldProbeClients {
+		c.Close()
+	}
+	return nil
+}
+
+// reloadCollectors returns the collectors of the enabled services of cfg, reusing the current
+// collector of every service whose configuration and client are unchanged. The caller must hold e.mu.
+func (e *Exporter) reloadCollectors(old, cfg *config.Config, c *client.Client, clientChanged bool) []collector.ServiceCollector {
+	current := make(map[string]collector.ServiceCollector, len(e.collectors))
+	for _, col := range e.collectors {
+		current[col.Name()] = col
+	}
+
+	collectors := make([]collector.ServiceCollector, 0, len(cfg.Services))
+	for _, name := range cfg.Services.Names() {
+		serviceConfig, _ := cfg.Services.Get(name)
+		if !serviceConfig.Enabled {
+			continue
+		}
+
+		col, found := current[name]
+		delete(current, name)
+		oldConfig, _ := old.Services.Get(name)
+		if found && !clientChanged && reflect.DeepEqual(oldConfig, serviceConfig) {
+			collectors = append(collectors, col)
+			continue
+		}
+
+		if found {
+			e.logger.WithService(name).Info("Service configuration changed, rebuilt collector")
+		} else {
+			e.logger.WithService(name).Info("Service enabled, added collector")
+		}
+		collectors = append(collectors, e.newCollector(cfg, c, name, serviceConfig))
+	}
+
+	for name := range current {
+		e.logger.WithService(name).Info("Service disabled, removed collector")
+	}
+	return collectors
+}
+
+// recordReload updates the reload metrics after a reload attempt
+func (e *Exporter) recordReload(success bool) {
+	if !success {
+		e.reloadSuccess.Set(0)
+		return
+	}
+	e.reloadSuccess.Set(1)
+	e.reloadTimestamp.Set(float64(time.Now().Unix()))
+}
+
+// ReloadHandler serves the /-/reload endpoint, reloading the configuration with load on POST
+// and PUT requests
+func (e *Exporter) ReloadHandler(load func() (*config.Config, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "Only POST or PUT requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := e.Reload(load); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, "Configuration reloaded")
+	})
+}
@@ -0,0 +1,259 @@
+package exporter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"alicloud-exporter/internal/client/clienttest"
+	"alicloud-exporter/internal/collector"
+	"alicloud-exporter/internal/config"
+)
+
+// reloadConfig returns a loader of a copy of the exporter's configuration changed by change
+func reloadConfig(e *Exporter, change func(*config.Config)) func() (*config.Config, error) {
+	return func() (*config.Config, error) {
+		current := e.GetConfig()
+		cfg := *current
+		cfg.Services = make(config.ServicesConfig, len(current.Services))
+		for name, service := range current.Services {
+			cfg.Services[name] = service
+		}
+		change(&cfg)
+		return &cfg, nil
+	}
+}
+
+// collectorsByName returns the active collectors of the exporter by service name
+func collectorsByName(e *Exporter) map[string]collector.ServiceCollector {
+	collectors := make(map[string]collector.ServiceCollector)
+	for _, col := range e.GetCollectors() {
+		collectors[col.Name()] = col
+	}
+	return collectors
+}
+
+func TestReloadCollectors(t *testing.T) {
+	e := newTestExporter(t, clienttest.New(), func(cfg *config.Config) {
+		cfg.Services = config.ServicesConfig{
+			"ecs":         {Enabled: true, Namespace: "acs_ecs_dashboard", Metrics: []string{"CPUUtilization"}},
+			"nat_gateway": {Enabled: true, Namespace: "acs_nat_gateway", Metrics: []string{"SnatConnection"}},
+			"oss":         {Enabled: true, Namespace: "acs_oss_dashboard", Metrics: []string{"UserStorage"}},
+		}
+	})
+	before := collectorsByName(e)
+
+	// Unchanged services keep their collector, changed and enabled ones get a new one
+	err := e.Reload(reloadConfig(e, func(cfg *config.Config) {
+		cfg.Services["nat_gateway"] = config.ServiceConfig{Enabled: true, Namespace: "acs_nat_gateway", Metrics: []string{"SnatConnection", "DropTotalPps"}}
+		cfg.Services["oss"] = config.ServiceConfig{Enabled: false, Namespace: "acs_oss_dashboard"}
+		cfg.Services["eip"] = config.ServiceConfig{Enabled: true, Namespace: "acs_vpc_eip", Metrics: []string{"net_rx.rate"}}
+	}))
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	after := collectorsByName(e)
+
+	if len(after) != 3 {
+		t.Errorf("got collectors %v, want ecs, eip and nat_gateway", after)
+	}
+	if after["ecs"] != before["ecs"] {
+		t.Error("unchanged ecs collector was rebuilt")
+	}
+	if after["nat_gateway"] == nil || after["nat_gateway"] == before["nat_gateway"] {
+		t.Error("changed nat_gateway collector was not rebuilt")
+	}
+	if after["eip"] == nil {
+		t.Error("enabled eip service has no collector")
+	}
+	if after["oss"] != nil {
+		t.Error("disabled oss service kept its collector")
+	}
+
+	// A new client rebuilds every collector
+	err = e.Reload(reloadConfig(e, func(cfg *config.Config) {
+		cfg.Alicloud.RateLimit.RequestsPerSecond = 500
+	}))
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	for name, col := range collectorsByName(e) {
+		if col == after[name] {
+			t.Errorf("collector %s was kept with the old client", name)
+		}
+	}
+}
+
+func TestReloadProbeTargets(t *testing.T) {
+	e := newTestExporter(t, clienttest.New(), func(cfg *config.Config) {
+		cfg.Services = config.ServicesConfig{
+			"ecs": {Enabled: true, Namespace: "acs_ecs_dashboard", Metrics: []string{"CPUUtilization"}},
+		}
+	})
+
+	probe := func() *ProbeTarget {
+		t.Helper()
+		target, _, err := e.probeTarget(e.GetConfig(), "", "cn-hangzhou")
+		if err != nil {
+			t.Fatalf("probeTarget() error = %v", err)
+		}
+		target.collector("ecs/", func() collector.ServiceCollector { return newStubCollector("ecs", nil) })
+		return target
+	}
+	target := probe()
+
+	// Service changes drop the collectors of probe targets and keep their clients
+	err := e.Reload(reloadConfig(e, func(cfg *config.Config) {
+		cfg.Services["ecs"] = config.ServiceConfig{Enabled: true, Namespace: "acs_ecs_dashboard", Metrics: []string{"cpu_total"}}
+	}))
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if reused := probe(); reused != target {
+		t.Error("probe target was replaced by a service change")
+	}
+	target.mu.Lock()
+	if len(target.collectors) != 1 {
+		t.Errorf("probe target has %d collectors, want only the one created after the reload", len(target.collectors))
+	}
+	target.mu.Unlock()
+
+	// Changes of the alicloud section or the accounts replace the probe targets
+	for _, change := range []func(*config.Config){
+		func(cfg *config.Config) { cfg.Alicloud.RateLimit.RequestsPerSecond = 500 },
+		func(cfg *config.Config) {
+			cfg.Accounts = []config.AccountConfig{{Name: "prod", AccessKeyID: "prod-key", AccessKeySecret: "prod-secret"}}
+		},
+	} {
+		if err := e.Reload(reloadConfig(e, change)); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+		if replaced := probe(); replaced == target {
+			t.Error("probe target was kept after the client configuration changed")
+		}
+		target = probe()
+	}
+}
+
+func TestReloadRestartsBackgroundScrape(t *testing.T) {
+	fake := clienttest.New()
+	fake.AddDatapoints("cn-hangzhou", "acs_ecs_dashboard", "CPUUtilization", clienttest.Datapoint{"instanceId": "i-1", "Average": 40.0})
+	fake.AddDatapoints("cn-hangzhou", "acs_nat_gateway", "SnatConnection", clienttest.Datapoint{"instanceId": "ngw-1", "Average": 7.0})
+	e := newTestExporter(t, fake, func(cfg *config.Config) {
+		cfg.Server.BackgroundScrape = true
+		cfg.Services = config.ServicesConfig{
+			"ecs": {Enabled: true, Namespace: "acs_ecs_dashboard", Metrics: []string{"CPUUtilization"}, ScrapeInterval: time.Hour},
+		}
+	})
+	waitFor(t, func() bool {
+		_, found := e.snapshots.Get("ecs")
+		return found
+	})
+	before, _ := e.snapshots.Get("ecs")
+
+	// The pollers are restarted with the new collectors and collect right away, rather than
+	// after the hour long interval
+	err := e.Reload(reloadConfig(e, func(cfg *config.Config) {
+		cfg.Services["nat_gateway"] = config.ServiceConfig{Enabled: true, Namespace: "acs_nat_gateway", Metrics: []string{"SnatConnection"}, ScrapeInterval: time.Hour}
+	}))
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	waitFor(t, func() bool {
+		_, natFound := e.snapshots.Get("nat_gateway")
+		after, _ := e.snapshots.Get("ecs")
+		return natFound && after != before
+	})
+	if e.stopBackground == nil {
+		t.Error("background scrape is not running after the reload")
+	}
+}
+
+func TestReloadHandler(t *testing.T) {
+	e := newTestExporter(t, clienttest.New(), nil)
+
+	var loadErr error
+	load := func() (*config.Config, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return reloadConfig(e, func(*config.Config) {})()
+	}
+	server := httptest.NewServer(e.ReloadHandler(load))
+	defer server.Close()
+
+	tests := []struct {
+		method     string
+		loadErr    error
+		wantStatus int
+	}{
+		{method: http.MethodPost, wantStatus: http.StatusOK},
+		{method: http.MethodPut, wantStatus: http.StatusOK},
+		{method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+		{method: http.MethodDelete, wantStatus: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, loadErr: errors.New("invalid yaml"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		loadErr = tt.loadErr
+		req, _ := http.NewRequest(tt.method, server.URL, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s error = %v", tt.method, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s status = %d, want %d", tt.method, resp.StatusCode, tt.wantStatus)
+		}
+		if tt.wantStatus == http.StatusMethodNotAllowed && resp.Header.Get("Allow") != "POST, PUT" {
+			t.Errorf("%s Allow = %q, want POST, PUT", tt.method, resp.Header.Get("Allow"))
+		}
+	}
+}
+
+func TestCloseWaitsForReload(t *testing.T) {
+	e := newTestExporter(t, clienttest.New(), func(cfg *config.Config) {
+		cfg.Server.BackgroundScrape = true
+	})
+
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	reloaded := make(chan error)
+	go func() {
+		reloaded <- e.Reload(func() (*config.Config, error) {
+			close(loading)
+			<-release
+			return reloadConfig(e, func(*config.Config) {})()
+		})
+	}()
+	<-loading
+
+	closed := make(chan struct{})
+	go func() {
+		e.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close() returned during a reload")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-reloaded; err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	<-closed
+
+	// The pollers restarted by the reload are stopped, later reloads are refused
+	if e.stopBackground != nil {
+		t.Error("background scrape is running after Close()")
+	}
+	if err := e.Reload(reloadConfig(e, func(*config.Config) {})); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("Reload() after Close() error = %v, want exporter is closed", err)
+	}
+}
@@ -0,0 +1,206 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/config"
+)
+
+// sdLabelPrefix is the prefix of the meta labels of discovered targets
+const sdLabelPrefix = "__meta_alicloud_"
+
+// TargetGroup is a target group of the Prometheus http_sd_config format
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// SDHandler serves /sd/targets?account=<name>&region=<region>&service=<name> in the Prometheus
+// http_sd_config format, with one target group per SLB, RDS and Redis instance. Targets are
+// instance IDs, meant to be relabeled to the instance parameter of /probe. Without a region every
+// region of the account is discovered.
+func (e *Exporter) SDHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		account := query.Get("account")
+
+		services, err := sdServices(query["service"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		clients, status, err := e.sdClients(e.GetConfig(), account, query.Get("region"))
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), MaxScrapeTimeout)
+		defer cancel()
+
+		groups := make([]TargetGroup, 0)
+		var errs []error
+		for _, service := range services {
+			for _, c := range clients {
+				// Regions that failed are skipped, the instances of the others are still returned
+				instances, err := describeInstances(ctx, c, service)
+				if err != nil {
+					e.logger.WithService(service).WithError(err).Error("Failed to discover instances")
+					errs = append(errs, fmt.Errorf("failed to discover %s instances: %w", service, err))
+				}
+
+				for _, instance := range instances {
+					groups = append(groups, TargetGroup{
+						Targets: []string{instance.ID},
+						Labels:  sdLabels(account, service, instance),
+					})
+				}
+			}
+		}
+
+		// Service discovery keeps the previous targets when a refresh fails, which is better than
+		// dropping every target when nothing could be listed
+		if len(groups) == 0 && len(errs) > 0 {
+			http.Error(w, errors.Join(errs...).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(groups); err != nil {
+			e.logger.WithError(err).Error("Failed to write service discovery response")
+		}
+	})
+}
+
+// sdClients returns the clients listing the instances of a discovery request, along with the HTTP
+// status to answer with when they can't be created. The alicloud section without a region is
+// served by the exporter's own client and inventory, accounts without a region by the pooled
+// probe clients of every region of the account.
+func (e *Exporter) sdClients(cfg *config.Config, account, region string) ([]client.DescribeAPI, int, error) {
+	if region != "" {
+		target, status, err := e.probeTarget(cfg, account, region)
+		if err != nil {
+			return nil, status, err
+		}
+		return []client.DescribeAPI{target.Client}, http.StatusOK, nil
+	}
+
+	if account == "" {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		return []client.DescribeAPI{e.client}, http.StatusOK, nil
+	}
+
+	regions, err := cfg.ProbeRegions(account)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	clients := make([]client.DescribeAPI, 0, len(regions))
+	for _, region := range regions {
+		target, status, err := e.probeTarget(cfg, account, region)
+		if err != nil {
+			return nil, status, err
+		}
+		clients = append(clients, target.Client)
+	}
+	return clients, http.StatusOK, nil
+}
+
+// sdServices resolves the services requested for discovery, defaulting to every service with
+// an inventory
+func sdServices(params []string) ([]string, error) {
+	var services []string
+	for _, param := range params {
+		for _, name := range strings.Split(param, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !containsString(config.TagServices, name) {
+				return nil, fmt.Errorf("unknown service: %s, must be one of %v", name, config.TagServices)
+			}
+			if !containsString(services, name) {
+				services = append(services, name)
+			}
+		}
+	}
+
+	if len(services) == 0 {
+		return config.TagServices, nil
+	}
+	return services, nil
+}
+
+// describeInstances lists the inventory of a service
+func describeInstances(ctx context.Context, c client.DescribeAPI, service string) ([]client.Instance, error) {
+	switch service {
+	case "slb":
+		return c.DescribeSLBInstances(ctx)
+	case "rds":
+		return c.DescribeRDSInstances(ctx)
+	case "redis":
+		return c.DescribeRedisInstances(ctx)
+	default:
+		return nil, fmt.Errorf("service %s has no inventory", service)
+	}
+}
+
+// sdLabels returns the meta labels of a discovered instance, omitting empty values
+func sdLabels(account, service string, instance client.Instance) map[string]string {
+	labels := map[string]string{
+		sdLabelPrefix + "service":     service,
+		sdLabelPrefix + "instance_id": instance.ID,
+		sdLabelPrefix + "region":      instance.Region,
+	}
+
+	optional := map[string]string{
+		"account":        account,
+		"name":           instance.Name,
+		"spec":           instance.Spec,
+		"engine":         instance.Engine,
+		"engine_version": instance.EngineVersion,
+		"network_type":   instance.NetworkType,
+		"vpc_id":         instance.VpcID,
+		"zone":           instance.Zone,
+		"charge_type":    instance.ChargeType,
+		"status":         instance.Status,
+	}
+	for name, value := range optional {
+		if value != "" {
+			labels[sdLabelPrefix+name] = value
+		}
+	}
+
+	// Tag keys such as team-a and team_a map to the same label, the first key in sorted order wins
+	keys := make([]string, 0, len(instance.Tags))
+	for key := range instance.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := sdLabelPrefix + "tag_" + config.TagLabelConfig{Tag: key}.LabelName()
+		if _, found := labels[name]; !found {
+			labels[name] = instance.Tags[key]
+		}
+	}
+
+	return labels
+}
+
+// containsString checks if a slice contains a string
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"alicloud-exporter/internal/client"
+	"alicloud-exporter/internal/client/clienttest"
+)
+
+func TestSDHandler(t *testing.T) {
+	fake := clienttest.New()
+	fake.Instances["slb"] = []client.Instance{{
+		ID:     "lb-1",
+		Region: "cn-hangzhou",
+		Name:   "web",
+		Status: "active",
+		Tags:   map[string]string{"Env": "prod", "team_a": "second", "team-a": "first"},
+	}}
+	fake.Instances["rds"] = []client.Instance{{
+		ID:     "rm-1",
+		Region: "cn-hangzhou",
+		Engine: "MySQL",
+		Tags:   map[string]string{"Team": "db"},
+	}}
+	e := newTestExporter(t, fake, nil)
+	server := httptest.NewServer(e.SDHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?service=slb,rds")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+
+	// The http_sd_config format is a list of objects with targets and labels only
+	decoder := json.NewDecoder(resp.Body)
+	decoder.DisallowUnknownFields()
+	var groups []TargetGroup
+	if err := decoder.Decode(&groups); err != nil {
+		t.Fatalf("decoding response error = %v", err)
+	}
+
+	want := []TargetGroup{
+		{
+			Targets: []string{"lb-1"},
+			Labels: map[string]string{
+				"__meta_alicloud_service":     "slb",
+				"__meta_alicloud_instance_id": "lb-1",
+				"__meta_alicloud_region":      "cn-hangzhou",
+				"__meta_alicloud_name":        "web",
+				"__meta_alicloud_status":      "active",
+				"__meta_alicloud_tag_Env":     "prod",
+				"__meta_alicloud_tag_team_a":  "first",
+			},
+		},
+		{
+			Targets: []string{"rm-1"},
+			Labels: map[string]string{
+				"__meta_alicloud_service":     "rds",
+				"__meta_alicloud_instance_id": "rm-1",
+				"__meta_alicloud_region":      "cn-hangzhou",
+				"__meta_alicloud_engine":      "MySQL",
+				"__meta_alicloud_tag_Team":    "db",
+			},
+		},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("target groups = %+v, want %+v", groups, want)
+	}
+}
+
+func TestSDHandlerErrors(t *testing.T) {
+	fake := clienttest.New()
+	fake.Errors["DescribeLoadBalancers"] = &clienttest.APIError{Status: 503, Code: "ServiceUnavailable"}
+	e := newTestExporter(t, fake, nil)
+	server := httptest.NewServer(e.SDHandler())
+	defer server.Close()
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{query: "service=ecs", wantStatus: http.StatusBadRequest, wantBody: "unknown service: ecs"},
+		{query: "service=slb&region=cn-shanghai", wantStatus: http.StatusBadRequest, wantBody: "region cn-shanghai is not configured"},
+		{query: "service=slb", wantStatus: http.StatusInternalServerError, wantBody: "failed to discover slb instances"},
+		{query: "service=rds", wantStatus: http.StatusOK, wantBody: "[]"},
+	}
+
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + "?" + tt.query)
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus || !strings.Contains(string(body), tt.wantBody) {
+			t.Errorf("GET ?%s = %d %q, want %d %q", tt.query, resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
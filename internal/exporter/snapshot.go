@@ -0,0 +1,201 @@
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"alicloud-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// defaultScrapeInterval is used for services without a scrape_interval in background mode
+	defaultScrapeInterval = 60 * time.Second
+)
+
+// Snapshot holds the metrics produced by the last background collection of a service
+type Snapshot struct {
+	Metrics   []prometheus.Metric
+	Timestamp time.Time
+	Result    collectorResult
+}
+
+// SnapshotStore keeps the latest snapshot for every service
+type SnapshotStore struct {
+	snapshots map[string]*Snapshot
+	mu        sync.RWMutex
+}
+
+// NewSnapshotStore creates an empty snapshot store
+func NewSnapshotStore() *SnapshotStore {
+	return &SnapshotStore{
+		snapshots: make(map[string]*Snapshot),
+	}
+}
+
+// Get returns the latest snapshot for a service
+func (s *SnapshotStore) Get(service string) (*Snapshot, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot, found := s.snapshots[service]
+	return snapshot, found
+}
+
+// Set replaces the snapshot for a service
+func (s *SnapshotStore) Set(service string, snapshot *Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[service] = snapshot
+}
+
+// startBackgroundScrape starts one polling loop per enabled collector
+func (e *Exporter) startBackgroundScrape() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.stopBackground = cancel
+
+	for _, col := range e.collectors {
+		if !col.Enabled() {
+			continue
+		}
+
+		e.backgroundWg.Add(1)
+		go e.pollCollector(ctx, col, e.scrapeInterval(col.Name()), e.collectTimeout(col.Name()))
+	}
+
+	e.backgroundWg.Add(1)
+	go e.pollHealth(ctx, defaultScrapeInterval)
+}
+
+// stopBackgroundScrape stops all polling loops and waits for them to exit
+func (e *Exporter) stopBackgroundScrape() {
+	if e.stopBackground == nil {
+		return
+	}
+	e.stopBackground()
+	e.backgroundWg.Wait()
+	e.stopBackground = nil
+}
+
+// pollCollector collects a service on its interval and stores the result as a snapshot
+func (e *Exporter) pollCollector(ctx context.Context, col collector.ServiceCollector, interval, timeout time.Duration) {
+	defer e.backgroundWg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.snapshots.Set(col.Name(), e.collectSnapshot(ctx, col, timeout))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectSnapshot runs a single collection of a service, bounded by timeout
+func (e *Exporter) collectSnapshot(ctx context.Context, col collector.ServiceCollector, timeout time.Duration) *Snapshot {
+	collectCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ch := make(chan prometheus.Metric, 1000)
+	done := make(chan []prometheus.Metric)
+	go func() {
+		metrics := make([]prometheus.Metric, 0)
+		for metric := range ch {
+			metrics = append(metrics, metric)
+		}
+		done <- metrics
+	}()
+
+	result := runCollector(collectCtx, col, ch)
+	close(ch)
+	metrics := <-done
+
+	if result.Err != nil {
+		// Counted here rather than when the snapshot is served, which may happen any number of times
+		e.scrapeErrors.Inc()
+		e.logger.WithField("service", col.Name()).WithError(result.Err).Error("Background collection error")
+	}
+	if result.TimedOut {
+		e.logger.WithService(col.Name()).Warn("Background collection ran out of time, keeping partial results")
+	}
+
+	return &Snapshot{
+		Metrics:   metrics,
+		Timestamp: time.Now(),
+		Result:    result,
+	}
+}
+
+// pollHealth checks client health on an interval so /metrics never blocks on it
+func (e *Exporter) pollHealth(ctx context.Context, interval time.Duration) {
+	defer e.backgroundWg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		healthCtx, cancel := context.WithTimeout(ctx, interval)
+		e.recordHealth(e.client.Health(healthCtx))
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scrapeInterval returns the configured scrape interval for a service
+func (e *Exporter) scrapeInterval(service string) time.Duration {
+	interval := e.config.Services[service].ScrapeInterval
+	if interval <= 0 {
+		return defaultScrapeInterval
+	}
+	return interval
+}
+
+// collectTimeout returns the timeout of a background collection of a service, which is its
+// configured timeout or its scrape interval
+func (e *Exporter) collectTimeout(service string) time.Duration {
+	if timeout := e.config.Services[service].Timeout; timeout > 0 {
+		return timeout
+	}
+	return e.scrapeInterval(service)
+}
+
+// collectSnapshots sends the latest snapshot of the given collectors along with its age
+func (e *Exporter) collectSnapshots(ch chan<- prometheus.Metric, collectors []collector.ServiceCollector) int {
+	errorCount := 0
+	for _, col := range collectors {
+		if !col.Enabled() {
+			continue
+		}
+
+		snapshot, found := e.snapshots.Get(col.Name())
+		if !found {
+			continue
+		}
+
+		for _, metric := range snapshot.Metrics {
+			ch <- metric
+		}
+
+		if snapshot.Result.Err != nil {
+			errorCount++
+		}
+		e.sendCollectorResult(ch, col.Name(), snapshot.Result)
+
+		ch <- prometheus.MustNewConstMetric(
+			e.snapshotAgeDesc,
+			prometheus.GaugeValue,
+			time.Since(snapshot.Timestamp).Seconds(),
+			col.Name(),
+		)
+	}
+	return errorCount
+}
@@ -0,0 +1,101 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"alicloud-exporter/internal/client/clienttest"
+	"alicloud-exporter/internal/collector"
+)
+
+func TestBackgroundSnapshots(t *testing.T) {
+	e := newTestExporter(t, clienttest.New("cn-hangzhou"), nil)
+
+	healthy := newStubCollector("healthy", nil)
+	broken := newStubCollector("broken", errors.New("boom"))
+	collectors := []collector.ServiceCollector{healthy, broken}
+
+	e.config.Server.BackgroundScrape = true
+	e.collectors = collectors
+	e.startBackgroundScrape()
+	waitFor(t, func() bool {
+		_, healthyFound := e.snapshots.Get("healthy")
+		_, brokenFound := e.snapshots.Get("broken")
+		return healthyFound && brokenFound
+	})
+	e.stopBackgroundScrape()
+
+	// Serving the snapshots repeatedly neither collects again nor counts the error again
+	var series map[string]float64
+	for i := 0; i < 3; i++ {
+		series = gather(t, e, context.Background(), collectors)
+	}
+	if calls := broken.calls.Load(); calls != 1 {
+		t.Errorf("broken collector ran %d times, want 1", calls)
+	}
+
+	want := map[string]float64{
+		`alicloud_healthy_stub{}`:                                1,
+		`alicloud_broken_stub{}`:                                 1,
+		`alicloud_scrape_collector_success{collector="healthy"}`: 1,
+		`alicloud_scrape_collector_success{collector="broken"}`:  0,
+		`alicloud_collector_timeout{service="healthy"}`:          0,
+		`alicloud_scrape_errors_total{}`:                         1,
+		`alicloud_last_scrape_error{}`:                           1,
+		`alicloud_scrapes_total{}`:                               3,
+		`alicloud_up{}`:                                          1,
+	}
+	for name, value := range want {
+		if got, found := series[name]; !found {
+			t.Errorf("missing series %s", name)
+		} else if got != value {
+			t.Errorf("series %s = %v, want %v", name, got, value)
+		}
+	}
+	for _, service := range []string{"healthy", "broken"} {
+		name := `alicloud_snapshot_age_seconds{service="` + service + `"}`
+		if age, found := series[name]; !found || age < 0 || age > 5 {
+			t.Errorf("series %s = %v, %t, want a recent age", name, age, found)
+		}
+	}
+
+	// The age grows with the time since the snapshot was taken
+	snapshot, _ := e.snapshots.Get("healthy")
+	e.snapshots.Set("healthy", &Snapshot{
+		Metrics:   snapshot.Metrics,
+		Timestamp: time.Now().Add(-30 * time.Second),
+		Result:    snapshot.Result,
+	})
+	series = gather(t, e, context.Background(), collectors)
+	if age := series[`alicloud_snapshot_age_seconds{service="healthy"}`]; age < 30 || age > 35 {
+		t.Errorf("snapshot age = %v, want about 30s", age)
+	}
+}
+
+func TestCollectSnapshotTimeout(t *testing.T) {
+	e := newTestExporter(t, clienttest.New("cn-hangzhou"), nil)
+
+	slow := newStubCollector("slow", nil)
+	slow.block = true
+	snapshot := e.collectSnapshot(context.Background(), slow, 20*time.Millisecond)
+
+	if !snapshot.Result.TimedOut || !errors.Is(snapshot.Result.Err, context.DeadlineExceeded) {
+		t.Errorf("snapshot result = %+v, want a timeout", snapshot.Result)
+	}
+	if len(snapshot.Metrics) != 1 {
+		t.Errorf("snapshot has %d metrics, want the 1 sent before the timeout", len(snapshot.Metrics))
+	}
+	if snapshot.Result.Duration < 20*time.Millisecond {
+		t.Errorf("snapshot duration = %v, want at least the timeout", snapshot.Result.Duration)
+	}
+
+	// The timeout is reported when the snapshot is served
+	e.config.Server.BackgroundScrape = true
+	e.snapshots.Set("slow", snapshot)
+	series := gather(t, e, context.Background(), []collector.ServiceCollector{slow})
+	if timedOut, found := series[`alicloud_collector_timeout{service="slow"}`]; !found || timedOut != 1 {
+		t.Errorf("alicloud_collector_timeout = %v, %t, want 1", timedOut, found)
+	}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"io"
 	"os"
 
 	"github.com/sirupsen/logrus"
@@ -45,6 +46,13 @@ func New(level, format string) *Logger {
 	return &Logger{Logger: logger}
 }
 
+// Discard creates a logger dropping every entry, for components not given a logger
+func Discard() *Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &Logger{Logger: logger}
+}
+
 // WithFields creates a new logger entry with fields
 func (l *Logger) WithFields(fields map[string]interface{}) *logrus.Entry {
 	return l.Logger.WithFields(fields)